@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Session is a single play, or a binge of consecutive episodes of one show.
+type Session struct {
+	Items []HistoryItem `json:"items"`
+}
+
+// IsBinge reports whether the session has at least BingeMinEpisodes
+// episodes.
+func (s *Session) IsBinge() bool {
+	return len(s.Items) >= AppConfig.BingeMinEpisodes
+}
+
+func (s *Session) Duration() int {
+	var total int
+	for _, item := range s.Items {
+		total += item.Duration
+	}
+	return total
+}
+
+// MarshalJSON includes IsBinge and Duration, as templates see them.
+func (s Session) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Items    []HistoryItem `json:"items"`
+		IsBinge  bool          `json:"is_binge"`
+		Duration int           `json:"duration"`
+	}{s.Items, s.IsBinge(), s.Duration()})
+}
+
+// groupSessions collapses runs of episodes of the same show into binge
+// sessions when each starts at most gap after the previous one ended. items
+// must be chronological. A zero gap disables grouping.
+func groupSessions(items []HistoryItem, gap time.Duration) []*Session {
+	var sessions []*Session
+	var current *Session
+	for _, item := range items {
+		if current != nil && gap > 0 && continuesBinge(current.Items[len(current.Items)-1], item, gap) {
+			current.Items = append(current.Items, item)
+			continue
+		}
+		if current != nil {
+			sessions = append(sessions, splitShortRun(current)...)
+		}
+		current = &Session{Items: []HistoryItem{item}}
+	}
+	if current != nil {
+		sessions = append(sessions, splitShortRun(current)...)
+	}
+	return sessions
+}
+
+func continuesBinge(prev, next HistoryItem, gap time.Duration) bool {
+	if prev.MediaType != "episode" || next.MediaType != "episode" || prev.Live == 1 || next.Live == 1 {
+		return false
+	}
+	if showTitle(prev) != showTitle(next) {
+		return false
+	}
+	return time.Duration(next.Date-itemEnd(prev))*time.Second <= gap
+}
+
+// itemEnd is when playback stopped, estimated from the duration when
+// Tautulli did not record it.
+func itemEnd(item HistoryItem) int64 {
+	if item.Stopped > 0 {
+		return item.Stopped
+	}
+	return item.Date + int64(item.Duration)
+}
+
+// splitShortRun turns a run too short to be a binge back into single plays.
+func splitShortRun(s *Session) []*Session {
+	if len(s.Items) == 1 || s.IsBinge() {
+		return []*Session{s}
+	}
+	sessions := make([]*Session, len(s.Items))
+	for i, item := range s.Items {
+		sessions[i] = &Session{Items: []HistoryItem{item}}
+	}
+	return sessions
+}
+
+// episodeRange renders "S02E03–E08", or "S01E09–S02E02" across seasons.
+func episodeRange(first, last HistoryItem) string {
+	if first.Season == 0 || last.Season == 0 {
+		return ""
+	}
+	if first.Season == last.Season {
+		return fmt.Sprintf("S%02dE%02d–E%02d", first.Season, first.Episode, last.Episode)
+	}
+	return fmt.Sprintf("S%02dE%02d–S%02dE%02d", first.Season, first.Episode, last.Season, last.Episode)
+}
+
+func formatBinge(s *Session, m markup) string {
+	first, last := s.Items[0], s.Items[len(s.Items)-1]
+	t := time.Unix(first.Date, 0).In(AppConfig.Location).Format("15:04:05")
+
+	what := fmt.Sprintf("%d episodes", len(s.Items))
+	if r := episodeRange(first, last); r != "" {
+		what = r
+	}
+	var rewatched int
+	for _, item := range s.Items {
+		if item.Rewatch {
+			rewatched++
+		}
+	}
+	switch {
+	case rewatched == len(s.Items):
+		what += " again 🔁"
+	case rewatched > 0:
+		what += fmt.Sprintf(" (%d rewatched 🔁)", rewatched)
+	}
+
+	return fmt.Sprintf("  🍿 Watched %s of %s (%s) %s\n",
+		what,
+		m.escape(showTitle(first)),
+		m.code(formatDuration(s.Duration())),
+		m.italic(fmt.Sprintf("@ %s (%s)", t, m.escape(first.Player))),
+	)
+}
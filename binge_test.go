@@ -0,0 +1,88 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// play is an episode of show starting start minutes after 20:00 and lasting
+// 40 minutes.
+func play(show string, start int) HistoryItem {
+	date := time.Date(2024, 5, 1, 20, start, 0, 0, time.UTC).Unix()
+	return HistoryItem{
+		Title: show + " - Episode", MediaType: "episode", GrandparentTitle: show,
+		Date: date, Stopped: date + 40*60, Duration: 40 * 60,
+	}
+}
+
+func TestGroupSessions(t *testing.T) {
+	tests := []struct {
+		name        string
+		items       []HistoryItem
+		gap         time.Duration
+		minEpisodes int
+		want        []int // episodes per session
+	}{
+		{
+			name:  "gap exactly at the limit",
+			items: []HistoryItem{play("Severance", 0), play("Severance", 70), play("Severance", 140)},
+			gap:   30 * time.Minute, minEpisodes: 3,
+			want: []int{3},
+		},
+		{
+			name:  "gap just over the limit",
+			items: []HistoryItem{play("Severance", 0), play("Severance", 70), play("Severance", 141)},
+			gap:   30 * time.Minute, minEpisodes: 3,
+			want: []int{1, 1, 1},
+		},
+		{
+			name:  "two episodes are no binge",
+			items: []HistoryItem{play("Severance", 0), play("Severance", 45)},
+			gap:   30 * time.Minute, minEpisodes: 3,
+			want: []int{1, 1},
+		},
+		{
+			name:  "two episodes with BINGE_MIN_EPISODES=2",
+			items: []HistoryItem{play("Severance", 0), play("Severance", 45)},
+			gap:   30 * time.Minute, minEpisodes: 2,
+			want: []int{2},
+		},
+		{
+			name:  "interleaved shows",
+			items: []HistoryItem{play("Severance", 0), play("Andor", 45), play("Severance", 90), play("Andor", 135)},
+			gap:   30 * time.Minute, minEpisodes: 2,
+			want: []int{1, 1, 1, 1},
+		},
+		{
+			name:  "run broken by another show",
+			items: []HistoryItem{play("Severance", 0), play("Severance", 45), play("Severance", 90), play("Andor", 135), play("Severance", 180)},
+			gap:   30 * time.Minute, minEpisodes: 3,
+			want: []int{3, 1, 1},
+		},
+		{
+			name:  "BINGE_GAP=0 disables grouping",
+			items: []HistoryItem{play("Severance", 0), play("Severance", 40), play("Severance", 80)},
+			gap:   0, minEpisodes: 3,
+			want: []int{1, 1, 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saved := AppConfig.BingeMinEpisodes
+			defer func() { AppConfig.BingeMinEpisodes = saved }()
+			AppConfig.BingeMinEpisodes = tt.minEpisodes
+
+			var got []int
+			for _, s := range groupSessions(tt.items, tt.gap) {
+				got = append(got, len(s.Items))
+				if s.IsBinge() != (len(s.Items) >= tt.minEpisodes) {
+					t.Errorf("session of %d episodes: IsBinge() = %v", len(s.Items), s.IsBinge())
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("sessions = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// calendarLevels shade a day by its watch time, see calendarLevel.
+var calendarLevels = []string{"·", "░", "▒", "▓", "█"}
+
+var calendarWeekdays = []string{"Mo", "Tu", "We", "Th", "Fr", "Sa", "Su"}
+
+// Calendar is a heatmap of daily watch time per user over whole weeks,
+// Monday to Sunday, ending with the week of To.
+type Calendar struct {
+	From  string          `json:"from"`
+	To    string          `json:"to"`
+	Weeks int             `json:"weeks"`
+	Users []*UserCalendar `json:"users"`
+}
+
+type UserCalendar struct {
+	Name     string `json:"name"`
+	UserID   int    `json:"user_id"`
+	Duration int    `json:"duration"`
+	// Days maps YYYY-MM-DD to the seconds watched that day.
+	Days map[string]int `json:"days"`
+	// CurrentStreak counts the active days up to the calendar's last day,
+	// or the day before when nothing was watched on it yet. Like
+	// LongestStreak it cannot reach further back than the calendar.
+	CurrentStreak int `json:"current_streak"`
+	// LongestStreak only looks at the calendar's weeks.
+	LongestStreak int `json:"longest_streak"`
+}
+
+// calendarPeriod covers the given number of whole weeks up to ref's week.
+func calendarPeriod(weeks int, ref time.Time) Period {
+	day := time.Date(ref.Year(), ref.Month(), ref.Day(), 0, 0, 0, 0, ref.Location())
+	monday := day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	return Period{From: monday.AddDate(0, 0, -7*(weeks-1)), To: day}
+}
+
+func buildCalendar(items []HistoryItem, weeks int, ref time.Time) *Calendar {
+	period := calendarPeriod(weeks, ref)
+	c := &Calendar{From: period.From.Format(dateLayout), To: period.To.Format(dateLayout), Weeks: weeks}
+
+	index := make(map[string]*UserCalendar)
+	for _, item := range items {
+		day := itemDay(item)
+		if day < c.From || day > c.To {
+			continue
+		}
+		u := index[item.Username]
+		if u == nil {
+			u = &UserCalendar{Name: item.Username, UserID: item.UserID, Days: make(map[string]int)}
+			index[item.Username] = u
+			c.Users = append(c.Users, u)
+		}
+		u.Days[day] += item.Duration
+		u.Duration += item.Duration
+	}
+
+	for _, u := range c.Users {
+		var active []string
+		for day := range u.Days {
+			active = append(active, day)
+		}
+		u.LongestStreak, _ = longestStreak(active)
+		u.CurrentStreak = currentStreak(u.Days, period.To)
+	}
+	sortGroups(c.Users, SortWatchTime, func(u *UserCalendar) (string, int, int64) { return u.Name, u.Duration, 0 })
+	return c
+}
+
+// currentStreak counts consecutive active days back from today, starting
+// from yesterday if today has no activity yet.
+func currentStreak(days map[string]int, today time.Time) int {
+	day := today
+	if _, ok := days[day.Format(dateLayout)]; !ok {
+		day = day.AddDate(0, 0, -1)
+	}
+	var streak int
+	for {
+		if _, ok := days[day.Format(dateLayout)]; !ok {
+			return streak
+		}
+		streak++
+		day = day.AddDate(0, 0, -1)
+	}
+}
+
+// calendarLevel buckets a day's watch time: nothing, under 1h, under 2h,
+// under 4h and more.
+func calendarLevel(seconds int) string {
+	switch {
+	case seconds <= 0:
+		return calendarLevels[0]
+	case seconds < 3600:
+		return calendarLevels[1]
+	case seconds < 2*3600:
+		return calendarLevels[2]
+	case seconds < 4*3600:
+		return calendarLevels[3]
+	}
+	return calendarLevels[4]
+}
+
+// onlyUsers keeps the users whose names are listed.
+func (c *Calendar) onlyUsers(names []string) {
+	c.Users = slices.DeleteFunc(c.Users, func(u *UserCalendar) bool { return !slices.Contains(names, u.Name) })
+}
+
+func writeCalendar(b *strings.Builder, c *Calendar, m markup) {
+	if c == nil || len(c.Users) == 0 {
+		return
+	}
+	from, _ := time.ParseInLocation(dateLayout, c.From, AppConfig.Location)
+	b.WriteString(fmt.Sprintf("🗓 %s %s\n", m.bold("Activity"), m.italic(fmt.Sprintf("(%s – %s)", c.From, c.To))))
+
+	for _, u := range c.Users {
+		b.WriteString(fmt.Sprintf("👤 %s", m.escape(u.Name)))
+		if u.CurrentStreak > 1 {
+			b.WriteString(fmt.Sprintf(" 🔥 %d days in a row", u.CurrentStreak))
+		}
+		if u.LongestStreak > 1 {
+			b.WriteString(fmt.Sprintf(" (best %d)", u.LongestStreak))
+		}
+		b.WriteString("\n")
+
+		for weekday, label := range calendarWeekdays {
+			var row strings.Builder
+			for week := range c.Weeks {
+				day := from.AddDate(0, 0, 7*week+weekday).Format(dateLayout)
+				if day > c.To {
+					row.WriteString(" ")
+					continue
+				}
+				row.WriteString(calendarLevel(u.Days[day]))
+			}
+			b.WriteString(fmt.Sprintf("  %s %s\n", m.code(label), m.code(row.String())))
+		}
+	}
+	b.WriteString(fmt.Sprintf("%s none %s <1h %s <2h %s <4h %s 4h+\n\n",
+		calendarLevels[0], calendarLevels[1], calendarLevels[2], calendarLevels[3], calendarLevels[4]))
+}
+
+func renderCalendar(c *Calendar, format string) (string, error) {
+	if format == FormatJSON {
+		return renderJSON(c)
+	}
+	m, err := markupFor(format)
+	if err != nil {
+		return "", err
+	}
+	if len(c.Users) == 0 {
+		return fmt.Sprintf("Nothing watched in the last %s.", plural(c.Weeks, "week")), nil
+	}
+	var b strings.Builder
+	writeCalendar(&b, c, m)
+	return b.String(), nil
+}
+
+// fetchCalendar builds the calendar for the CALENDAR_WEEKS weeks up to
+// ref, for one user when userName is set.
+func fetchCalendar(userName string, ref time.Time) (*Calendar, error) {
+	opts := calendarPeriod(AppConfig.CalendarWeeks, ref).Request()
+	if userName != "" {
+		user, err := findUser(userName)
+		if err != nil {
+			return nil, err
+		}
+		opts.UserID = user.UserID
+	}
+
+	history, err := fetchAllHistory(opts)
+	if err != nil {
+		return nil, err
+	}
+	return buildCalendar(history.History, AppConfig.CalendarWeeks, ref), nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Comparison is the change of a summary against the previous equivalent
+// period.
+type Comparison struct {
+	Previous string       `json:"previous_period"`
+	Users    []*UserDelta `json:"users"`
+}
+
+type UserDelta struct {
+	Name     string `json:"name"`
+	Current  int    `json:"current"`
+	Previous int    `json:"previous"`
+	// NewShows were watched in this period but not in the previous one,
+	// DroppedShows the other way round.
+	NewShows     []string `json:"new_shows"`
+	DroppedShows []string `json:"dropped_shows"`
+}
+
+// Change renders the watch time change as an arrow and percentage.
+func (d *UserDelta) Change() string {
+	switch {
+	case d.Previous == 0 && d.Current == 0:
+		return "＝"
+	case d.Previous == 0:
+		return "🆕"
+	case d.Current == d.Previous:
+		return "＝ 0%"
+	}
+	pct := float64(d.Current-d.Previous) / float64(d.Previous) * 100
+	if pct > 0 {
+		return fmt.Sprintf("▲ %.0f%%", pct)
+	}
+	return fmt.Sprintf("▼ %.0f%%", -pct)
+}
+
+func buildComparison(current, previous *Summary) *Comparison {
+	c := &Comparison{Previous: previous.Period}
+	deltas := make(map[string]*UserDelta)
+	delta := func(name string) *UserDelta {
+		d := deltas[name]
+		if d == nil {
+			d = &UserDelta{Name: name}
+			deltas[name] = d
+			c.Users = append(c.Users, d)
+		}
+		return d
+	}
+
+	for _, u := range current.Users {
+		delta(u.Name).Current = u.Duration
+	}
+	for _, u := range previous.Users {
+		delta(u.Name).Previous = u.Duration
+	}
+
+	for _, d := range c.Users {
+		titles := showTitles(current, d.Name)
+		before := showTitles(previous, d.Name)
+		for _, title := range titles {
+			if !slices.Contains(before, title) {
+				d.NewShows = append(d.NewShows, title)
+			}
+		}
+		for _, title := range before {
+			if !slices.Contains(titles, title) {
+				d.DroppedShows = append(d.DroppedShows, title)
+			}
+		}
+	}
+
+	sortGroups(c.Users, SortWatchTime, func(d *UserDelta) (string, int, int64) { return d.Name, d.Current, 0 })
+	return c
+}
+
+func showTitles(s *Summary, user string) []string {
+	var titles []string
+	for _, u := range s.Users {
+		if u.Name == user {
+			for _, g := range u.Shows {
+				titles = append(titles, g.Title)
+			}
+		}
+	}
+	return titles
+}
+
+func writeComparison(b *strings.Builder, c *Comparison, m markup) {
+	if c == nil || len(c.Users) == 0 {
+		return
+	}
+	b.WriteString(fmt.Sprintf("📈 %s %s\n", m.bold("Compared to"), m.italic(m.escape(c.Previous))))
+	for _, d := range c.Users {
+		b.WriteString(fmt.Sprintf("  👤 %s: %s → %s %s\n",
+			m.escape(d.Name), m.code(formatDuration(d.Previous)), m.code(formatDuration(d.Current)), d.Change()))
+		if len(d.NewShows) > 0 {
+			b.WriteString(fmt.Sprintf("    ➕ Started: %s\n", escapeJoin(d.NewShows, m)))
+		}
+		if len(d.DroppedShows) > 0 {
+			b.WriteString(fmt.Sprintf("    ➖ Dropped: %s\n", escapeJoin(d.DroppedShows, m)))
+		}
+	}
+	b.WriteString("\n")
+}
+
+func escapeJoin(items []string, m markup) string {
+	escaped := make([]string, len(items))
+	for i, item := range items {
+		escaped[i] = m.escape(item)
+	}
+	return strings.Join(escaped, ", ")
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestUserDeltaChange(t *testing.T) {
+	tests := []struct {
+		current, previous int
+		want              string
+	}{
+		{0, 0, "＝"},
+		{3600, 0, "🆕"},
+		{0, 3600, "▼ 100%"},
+		{3600, 3600, "＝ 0%"},
+		{5400, 3600, "▲ 50%"},
+		{7200, 3600, "▲ 100%"},
+		{1200, 3600, "▼ 67%"},
+	}
+	for _, tt := range tests {
+		d := &UserDelta{Current: tt.current, Previous: tt.previous}
+		if got := d.Change(); got != tt.want {
+			t.Errorf("Change() with %d now and %d before = %q, want %q", tt.current, tt.previous, got, tt.want)
+		}
+	}
+}
+
+func TestBuildComparison(t *testing.T) {
+	saved := AppConfig.SortBy
+	defer func() { AppConfig.SortBy = saved }()
+	AppConfig.SortBy = SortWatchTime
+
+	shows := func(titles ...string) []*ShowGroup {
+		var groups []*ShowGroup
+		for _, title := range titles {
+			groups = append(groups, &ShowGroup{Title: title})
+		}
+		return groups
+	}
+	current := &Summary{Users: []*UserSummary{
+		{Name: "alice", Duration: 600, Shows: shows("Severance", "Andor")},
+		{Name: "carol", Duration: 900, Shows: shows("Shogun")},
+	}}
+	previous := &Summary{Period: "2024-04-22 – 2024-04-28", Users: []*UserSummary{
+		{Name: "alice", Duration: 300, Shows: shows("Severance", "Silo")},
+		{Name: "bob", Duration: 1200, Shows: shows("Silo")},
+	}}
+
+	c := buildComparison(current, previous)
+	if c.Previous != previous.Period {
+		t.Errorf("Previous = %q, want %q", c.Previous, previous.Period)
+	}
+	var names []string
+	for _, d := range c.Users {
+		names = append(names, d.Name)
+	}
+	if want := []string{"carol", "alice", "bob"}; !slices.Equal(names, want) {
+		t.Fatalf("users = %v, want %v ordered by current watch time", names, want)
+	}
+
+	alice := c.Users[1]
+	if alice.Current != 600 || alice.Previous != 300 {
+		t.Errorf("alice = %d → %d, want 300 → 600", alice.Previous, alice.Current)
+	}
+	if !slices.Equal(alice.NewShows, []string{"Andor"}) || !slices.Equal(alice.DroppedShows, []string{"Silo"}) {
+		t.Errorf("alice started %v and dropped %v, want [Andor] and [Silo]", alice.NewShows, alice.DroppedShows)
+	}
+	if bob := c.Users[2]; bob.Current != 0 || !slices.Equal(bob.DroppedShows, []string{"Silo"}) {
+		t.Errorf("bob = %+v, want no watch time and Silo dropped", bob)
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// completeThreshold is the share of a title that counts as finished.
+const completeThreshold = 0.95
+
+// completionListSize limits the unfinished movies and abandoned shows listed.
+const completionListSize = 10
+
+// Completion shows how much of what was started also got finished.
+type Completion struct {
+	// Period is left empty when the completion is part of a summary.
+	Period string             `json:"period,omitempty"`
+	Users  []*UserCompletion  `json:"users"`
+	Movies []*MovieCompletion `json:"movies"`
+	// Abandoned is only filled by /completion.
+	Abandoned []*AbandonedShow `json:"abandoned,omitempty"`
+}
+
+// UserCompletion counts movies and episodes once per title, by the
+// furthest any of their plays got.
+type UserCompletion struct {
+	Name     string `json:"name"`
+	Titles   int    `json:"titles"`
+	Finished int    `json:"finished"`
+	// Average is the mean completion in percent.
+	Average int `json:"average"`
+}
+
+// MovieCompletion is a movie at least one user started but did not finish.
+type MovieCompletion struct {
+	Title    string `json:"title"`
+	Started  int    `json:"started"`
+	Finished int    `json:"finished"`
+	Average  int    `json:"average"`
+}
+
+// AbandonedShow is a show a user stopped watching with episodes left.
+type AbandonedShow struct {
+	*SeriesProgress
+	Episodes int `json:"episodes"`
+}
+
+// completion is the watched share of an item from 0 to 1, preferring
+// Tautulli's percent_complete over the coarser watched_status.
+func completion(item HistoryItem) float64 {
+	if item.PercentComplete > 0 {
+		return min(float64(item.PercentComplete)/100, 1)
+	}
+	return item.WatchedStatus
+}
+
+// buildCompletion aggregates the movies and episodes in items. Abandoned
+// shows are left to findAbandoned, which needs a long history to be useful.
+func buildCompletion(items []HistoryItem) *Completion {
+	c := &Completion{}
+
+	// Furthest completion per user and title.
+	type watch struct{ user, mediaType, title string }
+	furthest := make(map[watch]float64)
+	var order []watch
+	for _, item := range items {
+		if item.MediaType != "movie" && item.MediaType != "episode" {
+			continue
+		}
+		w := watch{item.Username, item.MediaType, item.Title}
+		if _, ok := furthest[w]; !ok {
+			order = append(order, w)
+		}
+		furthest[w] = max(furthest[w], completion(item))
+	}
+
+	users := make(map[string]*UserCompletion)
+	movies := make(map[string]*MovieCompletion)
+	userTotals := make(map[string]float64)
+	movieTotals := make(map[string]float64)
+	for _, w := range order {
+		done := furthest[w]
+		u := users[w.user]
+		if u == nil {
+			u = &UserCompletion{Name: w.user}
+			users[w.user] = u
+			c.Users = append(c.Users, u)
+		}
+		u.Titles++
+		userTotals[w.user] += done
+		if done >= completeThreshold {
+			u.Finished++
+		}
+
+		if w.mediaType != "movie" {
+			continue
+		}
+		m := movies[w.title]
+		if m == nil {
+			m = &MovieCompletion{Title: w.title}
+			movies[w.title] = m
+			c.Movies = append(c.Movies, m)
+		}
+		m.Started++
+		movieTotals[w.title] += done
+		if done >= completeThreshold {
+			m.Finished++
+		}
+	}
+
+	for _, u := range c.Users {
+		u.Average = int(userTotals[u.Name] * 100 / float64(u.Titles))
+	}
+	slices.SortFunc(c.Users, func(a, b *UserCompletion) int {
+		return cmp.Or(cmp.Compare(b.Average, a.Average), cmp.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)))
+	})
+
+	c.Movies = slices.DeleteFunc(c.Movies, func(m *MovieCompletion) bool { return m.Finished == m.Started })
+	for _, m := range c.Movies {
+		m.Average = int(movieTotals[m.Title] * 100 / float64(m.Started))
+	}
+	// Titles nobody finishes first, then by how many gave up.
+	slices.SortFunc(c.Movies, func(a, b *MovieCompletion) int {
+		return cmp.Or(
+			cmp.Compare(a.Finished, b.Finished),
+			cmp.Compare(b.Started-b.Finished, a.Started-a.Finished),
+			cmp.Compare(a.Title, b.Title),
+		)
+	})
+	c.Movies = c.Movies[:min(completionListSize, len(c.Movies))]
+	return c
+}
+
+// findAbandoned returns the shows whose last play per user in items is
+// older than ABANDON_AFTER at ref with episodes left, most recently given
+// up first. Checking for episodes left needs Tautulli's episode lists.
+func findAbandoned(items []HistoryItem, ref time.Time) []*AbandonedShow {
+	cutoff := ref.Add(-AppConfig.AbandonAfter).Unix()
+	episodes := make(map[[2]string]map[[2]int]bool)
+	for _, item := range items {
+		if item.MediaType != "episode" {
+			continue
+		}
+		key := [2]string{item.Username, showTitle(item)}
+		if episodes[key] == nil {
+			episodes[key] = make(map[[2]int]bool)
+		}
+		episodes[key][[2]int{int(item.Season), int(item.Episode)}] = true
+	}
+
+	var stale []*SeriesProgress
+	for _, p := range buildProgress(items) {
+		if p.LastWatched < cutoff {
+			stale = append(stale, p)
+		}
+	}
+	fillBehind(stale)
+
+	var abandoned []*AbandonedShow
+	for _, p := range stale {
+		if p.Behind > 0 {
+			abandoned = append(abandoned, &AbandonedShow{SeriesProgress: p, Episodes: len(episodes[[2]string{p.User, p.Show}])})
+		}
+	}
+	slices.SortFunc(abandoned, func(a, b *AbandonedShow) int { return cmp.Compare(b.LastWatched, a.LastWatched) })
+	return abandoned[:min(completionListSize, len(abandoned))]
+}
+
+func writeCompletion(b *strings.Builder, c *Completion, m markup) {
+	if c == nil || len(c.Users) == 0 {
+		return
+	}
+	header := m.bold("Completion")
+	if c.Period != "" {
+		header += " " + m.italic(m.escape(c.Period))
+	}
+	b.WriteString(fmt.Sprintf("✅ %s\n", header))
+	for _, u := range c.Users {
+		b.WriteString(fmt.Sprintf("  👤 %s: %s average, %d/%d finished\n",
+			m.escape(u.Name), m.code(fmt.Sprintf("%d%%", u.Average)), u.Finished, u.Titles))
+	}
+
+	if len(c.Movies) > 0 {
+		b.WriteString("  🎬 Unfinished movies:\n")
+		for _, movie := range c.Movies {
+			b.WriteString(fmt.Sprintf("    %s — started by %d, finished by %d (%s average)\n",
+				m.escape(movie.Title), movie.Started, movie.Finished, m.code(fmt.Sprintf("%d%%", movie.Average))))
+		}
+	}
+
+	if len(c.Abandoned) > 0 {
+		b.WriteString("  🛑 Abandoned shows:\n")
+		for _, a := range c.Abandoned {
+			b.WriteString(fmt.Sprintf("    %s: %s after %s (%s, %d left) %s\n",
+				m.escape(a.User), m.escape(a.Show), plural(a.Episodes, "episode"),
+				m.code(fmt.Sprintf("S%02dE%02d", a.Season, a.Episode)), a.Behind,
+				m.italic(time.Unix(a.LastWatched, 0).In(AppConfig.Location).Format(dateLayout))))
+		}
+	}
+	b.WriteString("\n")
+}
+
+func renderCompletion(c *Completion, format string) (string, error) {
+	if format == FormatJSON {
+		return renderJSON(c)
+	}
+	m, err := markupFor(format)
+	if err != nil {
+		return "", err
+	}
+	if len(c.Users) == 0 {
+		return "No movies or episodes watched.", nil
+	}
+	var b strings.Builder
+	writeCompletion(&b, c, m)
+	return b.String(), nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// seedEpisodes caches a show with n episodes in its first season for the
+// rest of the test, so no episode lists are fetched.
+func seedEpisodes(t *testing.T, showKey, n int) {
+	var episodes [][2]int
+	for i := 1; i <= n; i++ {
+		episodes = append(episodes, [2]int{1, i})
+	}
+	episodeCacheMu.Lock()
+	episodeCache[showKey] = cachedEpisodes{episodes: episodes, fetched: time.Now()}
+	episodeCacheMu.Unlock()
+	t.Cleanup(func() {
+		episodeCacheMu.Lock()
+		delete(episodeCache, showKey)
+		episodeCacheMu.Unlock()
+	})
+}
+
+func TestFindAbandoned(t *testing.T) {
+	saved := AppConfig.AbandonAfter
+	defer func() { AppConfig.AbandonAfter = saved }()
+	AppConfig.AbandonAfter = 30 * 24 * time.Hour
+
+	seedEpisodes(t, 100, 4)
+
+	ref := time.Date(2024, 5, 31, 12, 0, 0, 0, time.UTC)
+	cutoff := ref.Add(-AppConfig.AbandonAfter).Unix()
+	episode := func(user string, showKey, number int, date int64) HistoryItem {
+		return HistoryItem{
+			Username: user, MediaType: "episode", GrandparentTitle: "Severance",
+			GrandparentRatingKey: FlexInt(showKey), Season: 1, Episode: FlexInt(number), Date: date,
+		}
+	}
+
+	tests := []struct {
+		name  string
+		items []HistoryItem
+		want  []string // users with the show abandoned, most recent first
+	}{
+		{
+			name:  "last play just before the threshold",
+			items: []HistoryItem{episode("alice", 100, 1, cutoff-86400), episode("alice", 100, 2, cutoff-1)},
+			want:  []string{"alice"},
+		},
+		{
+			name:  "last play at the threshold",
+			items: []HistoryItem{episode("alice", 100, 1, cutoff-86400), episode("alice", 100, 2, cutoff)},
+		},
+		{
+			name:  "an earlier episode after the threshold still counts as watching",
+			items: []HistoryItem{episode("alice", 100, 2, cutoff-86400), episode("alice", 100, 1, cutoff+60)},
+		},
+		{
+			name:  "finished shows are not abandoned",
+			items: []HistoryItem{episode("alice", 100, 4, cutoff-86400)},
+		},
+		{
+			name:  "shows without an episode list are skipped",
+			items: []HistoryItem{episode("alice", 0, 1, cutoff-86400)},
+		},
+		{
+			name:  "per user, most recently given up first",
+			items: []HistoryItem{episode("alice", 100, 1, cutoff-7*86400), episode("bob", 100, 3, cutoff-86400), episode("carol", 100, 2, cutoff+60)},
+			want:  []string{"bob", "alice"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, a := range findAbandoned(tt.items, ref) {
+				got = append(got, a.User)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("abandoned by %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindAbandonedCountsEpisodes(t *testing.T) {
+	saved := AppConfig.AbandonAfter
+	defer func() { AppConfig.AbandonAfter = saved }()
+	AppConfig.AbandonAfter = 30 * 24 * time.Hour
+
+	seedEpisodes(t, 100, 4)
+
+	ref := time.Date(2024, 5, 31, 12, 0, 0, 0, time.UTC)
+	old := ref.AddDate(0, -2, 0).Unix()
+	var items []HistoryItem
+	for _, number := range []int{1, 2, 2} {
+		items = append(items, HistoryItem{
+			Username: "alice", MediaType: "episode", GrandparentTitle: "Severance",
+			GrandparentRatingKey: 100, Season: 1, Episode: FlexInt(number), Date: old,
+		})
+	}
+
+	abandoned := findAbandoned(items, ref)
+	if len(abandoned) != 1 {
+		t.Fatalf("got %d abandoned shows, want 1", len(abandoned))
+	}
+	if a := abandoned[0]; a.Episodes != 2 || a.Behind != 2 || a.Episode != 2 {
+		t.Errorf("got %d episodes watched, %d behind after E%d; want 2, 2 after E2", a.Episodes, a.Behind, a.Episode)
+	}
+}
@@ -3,20 +3,36 @@ package main
 import (
 	"log"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
+	_ "time/tzdata" // timezone database for minimal container images
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	TautulliURL          string
-	APIKey               string
-	GotifyURL            string
-	GotifyToken          string
-	TelegramBotToken     string
-	AllowedTelegramIDs   map[int64]bool
-	DailySummarySchedule string
+	TautulliURL         string
+	APIKey              string
+	GotifyURL           string
+	GotifyToken         string
+	GotifyFormat        string
+	TelegramBotToken    string
+	AllowedTelegramIDs  map[int64]bool
+	TelegramNotifyChats []int64
+	Reports             []ReportJob
+	HTTPListenAddr      string
+	Location            *time.Location
+	SortBy              string
+	Sections            []string
+	BingeGap            time.Duration
+	BingeMinEpisodes    int
+	StateFile           string
+	CatchupLookback     time.Duration
+	CalendarWeeks       int
+	AbandonAfter        time.Duration
+	MarkRewatches       bool
 }
 
 var AppConfig Config
@@ -25,29 +41,158 @@ func LoadConfig() {
 	_ = godotenv.Load()
 
 	AppConfig = Config{
-		TautulliURL:          os.Getenv("TAUTULLI_URL"),
-		APIKey:               os.Getenv("TAUTULLI_API_KEY"),
-		GotifyURL:            os.Getenv("GOTIFY_URL"),
-		GotifyToken:          os.Getenv("GOTIFY_TOKEN"),
-		TelegramBotToken:     os.Getenv("TELEGRAM_TOKEN"),
-		AllowedTelegramIDs:   make(map[int64]bool),
-		DailySummarySchedule: os.Getenv("DAILY_SUMMARY_SCHEDULE"),
+		TautulliURL:        os.Getenv("TAUTULLI_URL"),
+		APIKey:             os.Getenv("TAUTULLI_API_KEY"),
+		GotifyURL:          os.Getenv("GOTIFY_URL"),
+		GotifyToken:        os.Getenv("GOTIFY_TOKEN"),
+		TelegramBotToken:   os.Getenv("TELEGRAM_TOKEN"),
+		AllowedTelegramIDs: make(map[int64]bool),
+		Reports:            loadReportJobs(),
+		HTTPListenAddr:     os.Getenv("HTTP_LISTEN_ADDR"),
 	}
 
-	idStr := os.Getenv("TELEGRAM_ALLOWED_USERS")
-	for _, id := range strings.Split(idStr, ",") {
+	for _, id := range parseIDList("TELEGRAM_ALLOWED_USERS") {
+		AppConfig.AllowedTelegramIDs[id] = true
+	}
+	AppConfig.TelegramNotifyChats = parseIDList("TELEGRAM_NOTIFY_CHATS")
+
+	AppConfig.Location = time.Local
+	if tz := os.Getenv("TIMEZONE"); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			log.Fatalf("Invalid TIMEZONE %q: %v", tz, err)
+		}
+		AppConfig.Location = loc
+	}
+
+	AppConfig.SortBy = strings.ToLower(os.Getenv("SORT_BY"))
+	if AppConfig.SortBy == "" {
+		AppConfig.SortBy = SortWatchTime
+	}
+	if !slices.Contains(sortKeys, AppConfig.SortBy) {
+		log.Fatalf("Invalid SORT_BY %q (expected one of %s)", AppConfig.SortBy, strings.Join(sortKeys, ", "))
+	}
+
+	sections, err := parseSections(os.Getenv("SUMMARY_SECTIONS"))
+	if err != nil {
+		log.Fatalf("Invalid SUMMARY_SECTIONS: %v", err)
+	}
+	AppConfig.Sections = sections
+
+	if dir := os.Getenv("TEMPLATE_DIR"); dir != "" {
+		if err := loadTemplates(dir); err != nil {
+			log.Fatalf("Failed to load templates from %s: %v", dir, err)
+		}
+	}
+
+	AppConfig.BingeGap = 30 * time.Minute
+	if gap := os.Getenv("BINGE_GAP"); gap != "" {
+		d, err := time.ParseDuration(gap)
+		if err != nil || d < 0 {
+			log.Fatalf("Invalid BINGE_GAP %q (expected a duration like 45m, 0 disables)", gap)
+		}
+		AppConfig.BingeGap = d
+	}
+
+	AppConfig.BingeMinEpisodes = 3
+	if episodes := os.Getenv("BINGE_MIN_EPISODES"); episodes != "" {
+		n, err := strconv.Atoi(episodes)
+		if err != nil || n < 2 {
+			log.Fatalf("Invalid BINGE_MIN_EPISODES %q (expected a number of at least 2)", episodes)
+		}
+		AppConfig.BingeMinEpisodes = n
+	}
+
+	AppConfig.StateFile = os.Getenv("STATE_FILE")
+	AppConfig.CatchupLookback = 72 * time.Hour
+	if lookback := os.Getenv("CATCHUP_MAX_LOOKBACK"); lookback != "" {
+		d, err := time.ParseDuration(lookback)
+		if err != nil || d < 0 {
+			log.Fatalf("Invalid CATCHUP_MAX_LOOKBACK %q (expected a duration like 48h)", lookback)
+		}
+		AppConfig.CatchupLookback = d
+	}
+
+	AppConfig.CalendarWeeks = 8
+	if weeks := os.Getenv("CALENDAR_WEEKS"); weeks != "" {
+		n, err := strconv.Atoi(weeks)
+		if err != nil || n < 1 {
+			log.Fatalf("Invalid CALENDAR_WEEKS %q (expected a positive number)", weeks)
+		}
+		AppConfig.CalendarWeeks = n
+	}
+
+	AppConfig.AbandonAfter = 30 * 24 * time.Hour
+	if after := os.Getenv("ABANDON_AFTER"); after != "" {
+		d, err := time.ParseDuration(after)
+		if err != nil || d <= 0 {
+			log.Fatalf("Invalid ABANDON_AFTER %q (expected a duration like 1440h)", after)
+		}
+		AppConfig.AbandonAfter = d
+	}
+
+	switch mark := strings.ToLower(os.Getenv("MARK_REWATCHES")); mark {
+	case "", "false", "no", "0":
+	case "true", "yes", "1":
+		AppConfig.MarkRewatches = true
+	default:
+		log.Fatalf("Invalid MARK_REWATCHES %q (expected true or false)", mark)
+	}
+
+	if AppConfig.TautulliURL == "" || AppConfig.APIKey == "" {
+		log.Fatal("Missing required environment variables: TAUTULLI_URL and TAUTULLI_API_KEY")
+	}
+	if (AppConfig.GotifyURL == "") != (AppConfig.GotifyToken == "") {
+		log.Fatal("GOTIFY_URL and GOTIFY_TOKEN must be set together")
+	}
+	switch AppConfig.GotifyFormat = strings.ToLower(os.Getenv("GOTIFY_FORMAT")); AppConfig.GotifyFormat {
+	case "":
+		AppConfig.GotifyFormat = FormatText
+	case FormatText, FormatMarkdown:
+	default:
+		log.Fatalf("Invalid GOTIFY_FORMAT %q (expected text or markdown)", AppConfig.GotifyFormat)
+	}
+	if len(AppConfig.TelegramNotifyChats) > 0 && !AppConfig.TelegramEnabled() {
+		log.Fatal("TELEGRAM_NOTIFY_CHATS requires TELEGRAM_TOKEN")
+	}
+}
+
+// parseIDList reads a comma-separated list of Telegram IDs from the named
+// environment variable.
+func parseIDList(name string) []int64 {
+	var ids []int64
+	for _, id := range strings.Split(os.Getenv(name), ",") {
 		id = strings.TrimSpace(id)
 		if id == "" {
 			continue
 		}
 		parsed, err := strconv.ParseInt(id, 10, 64)
 		if err != nil {
-			log.Fatalf("Invalid TELEGRAM_ALLOWED_USERS entry: %s", id)
+			log.Fatalf("Invalid %s entry: %s", name, id)
 		}
-		AppConfig.AllowedTelegramIDs[parsed] = true
+		ids = append(ids, parsed)
 	}
+	return ids
+}
 
-	if AppConfig.TautulliURL == "" || AppConfig.APIKey == "" || AppConfig.GotifyURL == "" || AppConfig.GotifyToken == "" {
-		log.Fatal("Missing required environment variables")
-	}
+func (c Config) TelegramEnabled() bool {
+	return c.TelegramBotToken != ""
+}
+
+func (c Config) GotifyEnabled() bool {
+	return c.GotifyURL != "" && c.GotifyToken != ""
+}
+
+func (c Config) SchedulerEnabled() bool {
+	return len(c.Reports) > 0
+}
+
+func (c Config) HTTPEnabled() bool {
+	return c.HTTPListenAddr != ""
+}
+
+// now returns the current time in the configured timezone. All date
+// arithmetic ("today", "yesterday", report periods) goes through it.
+func now() time.Time {
+	return time.Now().In(AppConfig.Location)
 }
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// UsageGroup is the watch time and play count of anything plays can be
+// grouped by.
+type UsageGroup struct {
+	Name     string `json:"name"`
+	Plays    int    `json:"plays"`
+	Duration int    `json:"duration"`
+}
+
+// usageSet accumulates UsageGroups in first-seen order.
+type usageSet struct {
+	groups []*UsageGroup
+	index  map[string]*UsageGroup
+}
+
+func (u *usageSet) add(name string, duration int) {
+	if name == "" {
+		name = "Unknown"
+	}
+	if u.index == nil {
+		u.index = make(map[string]*UsageGroup)
+	}
+	group := u.index[name]
+	if group == nil {
+		group = &UsageGroup{Name: name}
+		u.index[name] = group
+		u.groups = append(u.groups, group)
+	}
+	group.Plays++
+	group.Duration += duration
+}
+
+// sorted returns the groups by watch time, longest first.
+func (u *usageSet) sorted() []*UsageGroup {
+	sortGroups(u.groups, SortWatchTime, func(g *UsageGroup) (string, int, int64) { return g.Name, g.Duration, 0 })
+	return u.groups
+}
+
+// DeviceUsage is watch time by platform, app and player.
+type DeviceUsage struct {
+	Platforms []*UsageGroup `json:"platforms"`
+	Products  []*UsageGroup `json:"products"`
+	Players   []*UsageGroup `json:"players"`
+}
+
+// DeviceBreakdown is device usage across all users and per user.
+type DeviceBreakdown struct {
+	DeviceUsage
+	Users []*UserDevices `json:"users"`
+}
+
+type UserDevices struct {
+	Name string `json:"name"`
+	DeviceUsage
+}
+
+type deviceCounter struct {
+	platforms, products, players usageSet
+}
+
+func (c *deviceCounter) add(item HistoryItem) {
+	c.platforms.add(item.Platform, item.Duration)
+	c.products.add(item.Product, item.Duration)
+	c.players.add(item.Player, item.Duration)
+}
+
+func (c *deviceCounter) usage() DeviceUsage {
+	return DeviceUsage{
+		Platforms: c.platforms.sorted(),
+		Products:  c.products.sorted(),
+		Players:   c.players.sorted(),
+	}
+}
+
+func buildDeviceBreakdown(items []HistoryItem) DeviceBreakdown {
+	var global deviceCounter
+	var names []string
+	users := make(map[string]*deviceCounter)
+
+	for _, item := range items {
+		global.add(item)
+		user := users[item.Username]
+		if user == nil {
+			user = &deviceCounter{}
+			users[item.Username] = user
+			names = append(names, item.Username)
+		}
+		user.add(item)
+	}
+
+	breakdown := DeviceBreakdown{DeviceUsage: global.usage()}
+	for _, name := range names {
+		breakdown.Users = append(breakdown.Users, &UserDevices{Name: name, DeviceUsage: users[name].usage()})
+	}
+	sortGroups(breakdown.Users, SortName, func(u *UserDevices) (string, int, int64) { return u.Name, 0, 0 })
+	return breakdown
+}
+
+func writeDevices(b *strings.Builder, d DeviceBreakdown, m markup) {
+	if len(d.Platforms) == 0 {
+		return
+	}
+	b.WriteString(fmt.Sprintf("📱 %s\n", m.bold("Devices")))
+	writeUsageLine(b, "Platforms", d.Platforms, m)
+	writeUsageLine(b, "Apps", d.Products, m)
+	writeUsageLine(b, "Players", d.Players, m)
+	for _, u := range d.Users {
+		b.WriteString(fmt.Sprintf("  👤 %s\n", m.bold(m.escape(u.Name))))
+		writeUsageLine(b, "  Platforms", u.Platforms, m)
+		writeUsageLine(b, "  Apps", u.Products, m)
+		writeUsageLine(b, "  Players", u.Players, m)
+	}
+	b.WriteString("\n")
+}
+
+// writeUsageLine writes "label: A 3h 5m (4), B 1h 0m (1)".
+func writeUsageLine(b *strings.Builder, label string, groups []*UsageGroup, m markup) {
+	parts := make([]string, len(groups))
+	for i, g := range groups {
+		parts[i] = fmt.Sprintf("%s %s (%d)", m.escape(g.Name), m.code(formatDuration(g.Duration)), g.Plays)
+	}
+	b.WriteString(fmt.Sprintf("  %s: %s\n", label, strings.Join(parts, ", ")))
+}
+
+// renderDevices renders only the device breakdown of s.
+func renderDevices(s *Summary, format string) (string, error) {
+	if format == FormatJSON {
+		return renderJSON(s.Devices)
+	}
+	m, err := markupFor(format)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	writeDevices(&b, s.Devices, m)
+	return b.String(), nil
+}
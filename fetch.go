@@ -3,7 +3,6 @@ package main
 import (
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
 	"regexp"
 	"strconv"
@@ -19,25 +18,83 @@ type HistoryRequest struct {
 	BeforeDate string
 	AllTime    bool
 	Compressed bool
+	UserID     int
+	MediaType  string
+	SectionID  int
+	// ShowKey limits the history to one show by its rating key.
+	ShowKey int
+}
+
+// Label describes the covered dates for titles and headers.
+func (r HistoryRequest) Label() string {
+	switch {
+	case r.AllTime:
+		return "all time"
+	case r.StartDate != "":
+		return r.StartDate
+	case r.AfterDate != "" && r.BeforeDate != "":
+		return r.AfterDate + " – " + r.BeforeDate
+	case r.AfterDate != "":
+		return "since " + r.AfterDate
+	case r.BeforeDate != "":
+		return "until " + r.BeforeDate
+	}
+	return "all time"
+}
+
+// End is the last day the request covers, or ref when it is open-ended.
+func (r HistoryRequest) End(ref time.Time) time.Time {
+	day := r.BeforeDate
+	if r.StartDate != "" {
+		day = r.StartDate
+	}
+	if r.AllTime || day == "" {
+		return ref
+	}
+	t, err := time.ParseInLocation(dateLayout, day, AppConfig.Location)
+	if err != nil {
+		return ref
+	}
+	return t
 }
 
 type HistoryItem struct {
-	Username          string  `json:"user"`
-	Title             string  `json:"full_title"`
-	MediaType         string  `json:"media_type"`
-	Date              int64   `json:"date"`
-	Platform          string  `json:"platform"`
-	Player            string  `json:"player"`
-	Product           string  `json:"product"`
-	IPAddress         string  `json:"ip_address"`
-	TranscodeDecision string  `json:"transcode_decision"`
-	Duration          int     `json:"duration"`
-	WatchedStatus     float64 `json:"watched_status"`
-	Episode           FlexInt `json:"media_index"`
-	Season            FlexInt `json:"parent_media_index"`
-	Live              int     `json:"live"`
-	GrandparentTitle  string  `json:"grandparent_title"`
-	State             string  `json:"state"`
+	Username             string  `json:"user"`
+	UserID               int     `json:"user_id"`
+	Title                string  `json:"full_title"`
+	RatingKey            FlexInt `json:"rating_key"`
+	MediaType            string  `json:"media_type"`
+	Date                 int64   `json:"date"`
+	Stopped              int64   `json:"stopped"`
+	Platform             string  `json:"platform"`
+	Player               string  `json:"player"`
+	Product              string  `json:"product"`
+	IPAddress            string  `json:"ip_address"`
+	TranscodeDecision    string  `json:"transcode_decision"`
+	Duration             int     `json:"duration"`
+	WatchedStatus        float64 `json:"watched_status"`
+	PercentComplete      FlexInt `json:"percent_complete"`
+	Episode              FlexInt `json:"media_index"`
+	Season               FlexInt `json:"parent_media_index"`
+	Live                 int     `json:"live"`
+	GrandparentTitle     string  `json:"grandparent_title"`
+	GrandparentRatingKey FlexInt `json:"grandparent_rating_key"`
+	ParentTitle          string  `json:"parent_title"`
+	ChannelCallSign      string  `json:"channel_call_sign"`
+	ChannelIdentifier    string  `json:"channel_identifier"`
+	State                string  `json:"state"`
+	// Rewatch is set by markRewatches, not by Tautulli.
+	Rewatch bool `json:"rewatch,omitempty"`
+}
+
+// MarshalJSON leaves out the viewer's IP address, since JSON summaries are
+// served without authentication.
+func (i HistoryItem) MarshalJSON() ([]byte, error) {
+	type item HistoryItem
+	return json.Marshal(struct {
+		item
+		IPAddress string `json:"ip_address,omitempty"`
+	}{item: item(i)})
 }
 
 type HistoryData struct {
@@ -87,11 +144,11 @@ func (i *FlexInt) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
-func validateDateFormat(dateStr string) {
-	_, err := time.Parse(dateLayout, dateStr)
-	if err != nil {
-		log.Fatalf("Invalid date format: %s (expected YYYY-MM-DD)", dateStr)
+func validateDateFormat(dateStr string) error {
+	if _, err := time.Parse(dateLayout, dateStr); err != nil {
+		return fmt.Errorf("invalid date format: %s (expected YYYY-MM-DD)", dateStr)
 	}
+	return nil
 }
 
 func fetchHistory(url string) (*HistoryData, error) {
@@ -118,14 +175,18 @@ func fetchAllHistory(opts HistoryRequest) (*HistoryData, error) {
 	var totalRecords int
 
 	for start := 0; ; start += 100 {
-		url := buildHistoryURL(opts, start)
+		url, err := buildHistoryURL(opts, start)
+		if err != nil {
+			return nil, err
+		}
 		data, err := fetchHistory(url)
 		if err != nil {
 			return nil, err
 		}
 
 		allItems = append(allItems, data.History...)
-		if data.TotalDuration != "" {
+		// filter_duration covers the whole query, so only the first page counts.
+		if start == 0 && data.TotalDuration != "" {
 			dur, err := parseCustomDuration(data.TotalDuration)
 			if err == nil {
 				totalDuration += dur
@@ -146,24 +207,43 @@ func fetchAllHistory(opts HistoryRequest) (*HistoryData, error) {
 	}, nil
 }
 
-func buildHistoryURL(opts HistoryRequest, start int) string {
+func buildHistoryURL(opts HistoryRequest, start int) (string, error) {
 	params := []string{}
 
 	if !opts.AllTime {
 		if opts.StartDate != "" {
-			validateDateFormat(opts.StartDate)
+			if err := validateDateFormat(opts.StartDate); err != nil {
+				return "", err
+			}
 			params = append(params, "start_date="+opts.StartDate)
 		}
 		if opts.AfterDate != "" {
-			validateDateFormat(opts.AfterDate)
+			if err := validateDateFormat(opts.AfterDate); err != nil {
+				return "", err
+			}
 			params = append(params, "after="+opts.AfterDate)
 		}
 		if opts.BeforeDate != "" {
-			validateDateFormat(opts.BeforeDate)
+			if err := validateDateFormat(opts.BeforeDate); err != nil {
+				return "", err
+			}
 			params = append(params, "before="+opts.BeforeDate)
 		}
 	}
 
+	if opts.UserID != 0 {
+		params = append(params, "user_id="+strconv.Itoa(opts.UserID))
+	}
+	if opts.MediaType != "" {
+		params = append(params, "media_type="+opts.MediaType)
+	}
+	if opts.SectionID != 0 {
+		params = append(params, "section_id="+strconv.Itoa(opts.SectionID))
+	}
+	if opts.ShowKey != 0 {
+		params = append(params, "grandparent_rating_key="+strconv.Itoa(opts.ShowKey))
+	}
+
 	params = append(params, "length=100")
 	params = append(params, "start="+strconv.Itoa(start))
 
@@ -171,80 +251,39 @@ func buildHistoryURL(opts HistoryRequest, start int) string {
 		AppConfig.TautulliURL,
 		AppConfig.APIKey,
 		strings.Join(params, "&"),
-	)
+	), nil
 }
-func fetchAllHistoryForDate(date string) (*HistoryData, error) {
-	if date != "" {
-		validateDateFormat(date)
-	}
-
-	params := make([]string, 0)
-	if date != "" {
-		params = append(params, "start_date="+date)
-	}
-
-	params = append(params, "length=100")
-
-	var allItems []HistoryItem
-	var totalDuration string
-	var totalRecords int
-
-	for start := 0; ; start += 100 {
-		fullParams := append(params, "start="+strconv.Itoa(start))
-		url := fmt.Sprintf("%s/api/v2?apikey=%s&cmd=get_history&%s",
-			AppConfig.TautulliURL,
-			AppConfig.APIKey,
-			strings.Join(fullParams, "&"),
-		)
-
-		data, err := fetchHistory(url)
-		if err != nil {
-			return nil, err
-		}
-
-		allItems = append(allItems, data.History...)
-		if totalDuration == "" {
-			totalDuration = data.TotalDuration
-			totalRecords = data.TotalRecords
-		}
-		if start >= totalRecords || len(data.History) == 0 {
-			break
-		}
-	}
 
-	return &HistoryData{
-		History:       allItems,
-		TotalDuration: totalDuration,
-		TotalRecords:  totalRecords,
-	}, nil
+// ActiveSession is a currently playing stream as reported by get_activity.
+type ActiveSession struct {
+	User     string  `json:"user"`
+	Title    string  `json:"title"`
+	Player   string  `json:"player"`
+	Platform string  `json:"platform"`
+	Duration int     `json:"duration"` // seconds
+	Progress float64 `json:"progress"` // percent watched
 }
 
-func fetchActiveSessions() (string, error) {
+func fetchActiveSessions() ([]ActiveSession, error) {
 	url := fmt.Sprintf("%s/api/v2?apikey=%s&cmd=get_activity", AppConfig.TautulliURL, AppConfig.APIKey)
 	resp, err := http.Get(url)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	var result ActiveResponse
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", err
-	}
-
-	sessions := result.Response.Data.Sessions
-	if len(sessions) == 0 {
-		return "No active sessions.", nil
+		return nil, err
 	}
 
-	var b strings.Builder
-	for _, s := range sessions {
+	var sessions []ActiveSession
+	for _, s := range result.Response.Data.Sessions {
 		duration, _ := strconv.Atoi(s.DurationStr) //get_activity returns it all as strings for some reason
 		offset, _ := strconv.Atoi(s.ViewOffsetStr)
 		season, _ := strconv.Atoi(s.SeasonStr)
 		episode, _ := strconv.Atoi(s.EpisodeStr)
 
-		durationMin := duration / 60000
 		var progress float64
 		if duration > 0 {
 			progress = float64(offset) / float64(duration) * 100
@@ -257,11 +296,18 @@ func fetchActiveSessions() (string, error) {
 			title = s.Title
 		}
 
-		fmt.Fprintf(&b, "▶️ %s is watching %s on %s [%s] for ~%d min [%.0f%% Watched]\n",
-			s.User, title, s.Player, s.Platform, durationMin, progress)
+		sessions = append(sessions, ActiveSession{
+			User:     s.User,
+			Title:    title,
+			Player:   s.Player,
+			Platform: s.Platform,
+			Duration: duration / 1000,
+			Progress: progress,
+		})
 	}
-	return b.String(), nil
+	return sessions, nil
 }
+
 func parseCustomDuration(s string) (time.Duration, error) {
 	var total time.Duration
 	re := regexp.MustCompile(`(\d+)\s*(days?|hrs?|mins?|secs?)`)
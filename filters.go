@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Media types accepted by get_history's media_type filter.
+var mediaTypes = []string{"movie", "episode", "track", "live"}
+
+type Library struct {
+	SectionID   FlexInt `json:"section_id"`
+	SectionName string  `json:"section_name"`
+	SectionType string  `json:"section_type"`
+}
+
+type LibrariesResponse struct {
+	Response struct {
+		Data []Library `json:"data"`
+	} `json:"response"`
+}
+
+func fetchLibraries() ([]Library, error) {
+	url := fmt.Sprintf("%s/api/v2?apikey=%s&cmd=get_libraries", AppConfig.TautulliURL, AppConfig.APIKey)
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result LibrariesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return result.Response.Data, nil
+}
+
+// findLibrary resolves a library section ID or (case-insensitive) name.
+func findLibrary(nameOrID string) (int, error) {
+	if id, err := strconv.Atoi(nameOrID); err == nil {
+		return id, nil
+	}
+
+	libraries, err := fetchLibraries()
+	if err != nil {
+		return 0, err
+	}
+	var names []string
+	for _, lib := range libraries {
+		if strings.EqualFold(lib.SectionName, nameOrID) {
+			return int(lib.SectionID), nil
+		}
+		names = append(names, lib.SectionName)
+	}
+	return 0, fmt.Errorf("unknown library %q, available: %s", nameOrID, strings.Join(names, ", "))
+}
+
+func parseMediaType(s string) (string, error) {
+	s = strings.ToLower(s)
+	if !slices.Contains(mediaTypes, s) {
+		return "", fmt.Errorf("unknown media type %q (expected one of %s)", s, strings.Join(mediaTypes, ", "))
+	}
+	return s, nil
+}
+
+// parseFilterArgs applies "type=movie" and "library=Kids" command arguments
+// to req and returns the arguments it did not consume.
+func parseFilterArgs(req *HistoryRequest, args []string) ([]string, error) {
+	var rest []string
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			rest = append(rest, arg)
+			continue
+		}
+
+		switch strings.ToLower(key) {
+		case "type":
+			mediaType, err := parseMediaType(value)
+			if err != nil {
+				return nil, err
+			}
+			req.MediaType = mediaType
+		case "library", "lib":
+			id, err := findLibrary(value)
+			if err != nil {
+				return nil, err
+			}
+			req.SectionID = id
+		default:
+			return nil, fmt.Errorf("unknown filter %q (expected type= or library=)", key)
+		}
+	}
+	return rest, nil
+}
@@ -1,188 +1,263 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"html"
 	"strings"
 	"time"
 )
 
-func generateAggregatedSummary(data *HistoryData) string {
-	type ShowGroup struct {
-		Episodes int
-		Duration int
+// Output formats a Summary can be rendered in.
+const (
+	FormatText     = "text"
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+	FormatJSON     = "json"
+)
+
+var summaryFormats = []string{FormatText, FormatMarkdown, FormatHTML, FormatJSON}
+
+// markup abstracts the few styling primitives the layouts use, so that the
+// same layout renders as plain text, Markdown or HTML. The style functions
+// receive text that has already been escaped.
+type markup struct {
+	escape func(string) string
+	bold   func(string) string
+	italic func(string) string
+	code   func(string) string
+}
+
+func plain(s string) string { return s }
+
+var textMarkup = markup{escape: plain, bold: plain, italic: plain, code: plain}
+
+var markdownMarkup = markup{
+	escape: strings.NewReplacer(
+		`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`,
+		"<", `\<`, ">", `\>`, "#", `\#`, "|", `\|`, "~", `\~`,
+	).Replace,
+	bold:   func(s string) string { return "**" + s + "**" },
+	italic: func(s string) string { return "_" + s + "_" },
+	code:   func(s string) string { return "`" + s + "`" },
+}
+
+var htmlMarkup = markup{
+	escape: html.EscapeString,
+	bold:   func(s string) string { return "<b>" + s + "</b>" },
+	italic: func(s string) string { return "<i>" + s + "</i>" },
+	code:   func(s string) string { return "<code>" + s + "</code>" },
+}
+
+func markupFor(format string) (markup, error) {
+	switch format {
+	case FormatText, "":
+		return textMarkup, nil
+	case FormatMarkdown:
+		return markdownMarkup, nil
+	case FormatHTML:
+		return htmlMarkup, nil
+	}
+	return markup{}, fmt.Errorf("unknown format %q", format)
+}
+
+func renderJSON(v any) (string, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
+
+// htmlDocument wraps rendered HTML into a standalone page. The layouts rely
+// on line breaks, so the body is preformatted.
+func htmlDocument(title, body string) string {
+	return "<!DOCTYPE html>\n<meta charset=\"utf-8\">\n<title>" + html.EscapeString(title) + "</title>\n<pre>\n" + body + "</pre>\n"
+}
+
+// renderSummary renders s in the given format, either item by item or
+// aggregated per show and movie when compressed is set.
+func renderSummary(s *Summary, format string, compressed bool) (string, error) {
+	if format == FormatJSON {
+		return renderJSON(s)
+	}
+	m, err := markupFor(format)
+	if err != nil {
+		return "", err
+	}
+
+	layout := LayoutDaily
+	if compressed {
+		layout = LayoutAggregated
 	}
-	type MovieGroup struct {
-		Count    int
-		Duration int
+	if out, ok := executeTemplate(layout, format, s); ok {
+		return out, nil
 	}
-	type UserSummary struct {
-		Movies map[string]*MovieGroup
-		Shows  map[string]*ShowGroup
-		Total  int
+
+	if compressed {
+		return renderAggregated(s, m), nil
 	}
+	return renderDetailed(s, m), nil
+}
 
-	// Global live TV data
-	liveByShow := make(map[string]int)
-	totalLive := 0
+// renderActive renders the currently playing sessions.
+func renderActive(sessions []ActiveSession, format string) (string, error) {
+	if format == FormatJSON {
+		return renderJSON(sessions)
+	}
+	m, err := markupFor(format)
+	if err != nil {
+		return "", err
+	}
 
-	users := make(map[string]*UserSummary)
+	if out, ok := executeTemplate(LayoutActive, format, struct{ Sessions []ActiveSession }{sessions}); ok {
+		return out, nil
+	}
 
-	for _, item := range data.History {
-		if item.Live == 1 {
-			title := item.GrandparentTitle
-			if title == "" {
-				title = item.Title
-			}
-			liveByShow[title] += item.Duration
-			totalLive += item.Duration
-			continue
-		}
+	if len(sessions) == 0 {
+		return "No active sessions.", nil
+	}
 
-		user := item.Username
-		if _, ok := users[user]; !ok {
-			users[user] = &UserSummary{
-				Movies: make(map[string]*MovieGroup),
-				Shows:  make(map[string]*ShowGroup),
-			}
+	var b strings.Builder
+	for _, s := range sessions {
+		fmt.Fprintf(&b, "▶️ %s is watching %s on %s [%s] for ~%d min [%.0f%% Watched]\n",
+			m.bold(m.escape(s.User)), m.escape(s.Title), m.escape(s.Player), m.escape(s.Platform), s.Duration/60, s.Progress)
+	}
+	return b.String(), nil
+}
+
+func renderDetailed(s *Summary, m markup) string {
+	var builder strings.Builder
+
+	if s.Live.Duration > 0 {
+		builder.WriteString(fmt.Sprintf("📡 You watched %s of Live TV\n", m.code(formatDuration(s.Live.Duration))))
+		for _, show := range s.Live.Shows {
+			builder.WriteString(fmt.Sprintf("  %s: %s\n", m.escape(show.Title), m.code(formatDuration(show.Duration))))
 		}
-		summary := users[user]
-		summary.Total += item.Duration
-
-		switch item.MediaType {
-		case "movie":
-			group := summary.Movies[item.Title]
-			if group == nil {
-				group = &MovieGroup{}
-				summary.Movies[item.Title] = group
-			}
-			group.Count++
-			group.Duration += item.Duration
+		if len(s.Live.Users) > 0 {
+			builder.WriteString(fmt.Sprintf("  👤 %s\n", usageList(s.Live.Users, m)))
+		}
+		builder.WriteString("\n")
+	}
 
-		case "episode":
-			title := item.GrandparentTitle
-			if title == "" {
-				title = item.Title
-			}
-			group := summary.Shows[title]
-			if group == nil {
-				group = &ShowGroup{}
-				summary.Shows[title] = group
+	for _, user := range s.Users {
+		builder.WriteString(fmt.Sprintf("%s (%s):\n", m.bold(m.escape(user.Name)), m.code(formatDuration(user.Duration))))
+		for _, session := range user.Sessions {
+			item := session.Items[0]
+			switch {
+			case session.IsBinge():
+				builder.WriteString(formatBinge(session, m))
+			case item.MediaType != "track":
+				// Tracks are summarized by artist below instead of one per line.
+				builder.WriteString(formatItem(item, m))
 			}
-			group.Episodes++
-			group.Duration += item.Duration
 		}
+		writeMusic(&builder, user, m)
+		builder.WriteString("\n")
 	}
 
+	writeSections(&builder, s, m)
+	builder.WriteString(fmt.Sprintf("🕒 Total duration: %s\n", m.code(m.escape(s.ReportedDuration))))
+
+	return builder.String()
+}
+
+func renderAggregated(s *Summary, m markup) string {
 	var b strings.Builder
 
 	// 🔊 Global live TV section
-	if totalLive > 0 {
-		b.WriteString(fmt.Sprintf("📡 You watched %s of Live TV\n", formatDuration(totalLive)))
-		for show, dur := range liveByShow {
-			b.WriteString(fmt.Sprintf("  - %s: %s\n", show, formatDuration(dur)))
+	if s.Live.Duration > 0 {
+		b.WriteString(fmt.Sprintf("📡 You watched %s of Live TV\n", m.code(formatDuration(s.Live.Duration))))
+		for _, show := range s.Live.Shows {
+			b.WriteString(fmt.Sprintf("  - %s: %s\n", m.escape(show.Title), m.code(formatDuration(show.Duration))))
+		}
+		if len(s.Live.Users) > 0 {
+			b.WriteString(fmt.Sprintf("  👤 %s\n", usageList(s.Live.Users, m)))
 		}
 		b.WriteString("\n")
 	}
 
 	// 👤 Per-user summaries
-	for user, summary := range users {
-		b.WriteString(fmt.Sprintf("👤 %s\n", user))
-
-		if len(summary.Movies) > 0 {
-			var dur int
-			b.WriteString(fmt.Sprintf("🎬 Movies (%d titles):\n", len(summary.Movies)))
-			for title, g := range summary.Movies {
-				b.WriteString(fmt.Sprintf("  - %s (%dx)\n", title, g.Count))
-				dur += g.Duration
+	for _, user := range s.Users {
+		b.WriteString(fmt.Sprintf("👤 %s\n", m.bold(m.escape(user.Name))))
+
+		if len(user.Movies) > 0 {
+			b.WriteString(fmt.Sprintf("🎬 Movies (%d titles):\n", len(user.Movies)))
+			for _, g := range user.Movies {
+				b.WriteString(fmt.Sprintf("  - %s (%dx)\n", m.escape(g.Title), g.Plays))
 			}
-			b.WriteString(fmt.Sprintf("  Total movie time: %s\n", formatDuration(dur)))
+			b.WriteString(fmt.Sprintf("  Total movie time: %s\n", m.code(formatDuration(user.MovieDuration()))))
 		}
 
-		if len(summary.Shows) > 0 {
-			var dur, eps int
-			b.WriteString(fmt.Sprintf("📺 Shows (%d titles):\n", len(summary.Shows)))
-			for title, g := range summary.Shows {
-				b.WriteString(fmt.Sprintf("  - %s (%d eps)\n", title, g.Episodes))
-				dur += g.Duration
-				eps += g.Episodes
+		if len(user.Shows) > 0 {
+			b.WriteString(fmt.Sprintf("📺 Shows (%d titles):\n", len(user.Shows)))
+			for _, g := range user.Shows {
+				b.WriteString(fmt.Sprintf("  - %s (%d eps)\n", m.escape(g.Title), g.Episodes))
 			}
-			b.WriteString(fmt.Sprintf("  Total: %d episodes — %s\n", eps, formatDuration(dur)))
+			eps, dur := user.ShowDuration()
+			b.WriteString(fmt.Sprintf("  Total: %d episodes — %s\n", eps, m.code(formatDuration(dur))))
 		}
 
-		b.WriteString(fmt.Sprintf("🕒 Total watched: %s\n\n", formatDuration(summary.Total)))
+		writeMusic(&b, user, m)
+
+		b.WriteString(fmt.Sprintf("🕒 Total watched: %s\n\n", m.code(formatDuration(user.Duration))))
 	}
 
-	b.WriteString(fmt.Sprintf("📊 Grand total duration: %s\n", data.TotalDuration))
+	writeSections(&b, s, m)
+	b.WriteString(fmt.Sprintf("📊 Grand total duration: %s\n", m.code(m.escape(s.ReportedDuration))))
 	return b.String()
 }
 
-func generateSummary(data *HistoryData, compressed bool) string {
-	if compressed {
-		return generateAggregatedSummary(data)
-	}
-	items := data.History
-	userSummaries := make(map[string][]string)
-	userDurations := make(map[string]int)
-	liveByShow := make(map[string]int)
-	totalLive := 0
-
-	for _, item := range items {
-		if item.Live == 1 {
-			show := item.GrandparentTitle
-			if show == "" {
-				show = item.Title
-			}
-			liveByShow[show] += item.Duration
-			totalLive += item.Duration
-			continue
-		}
-		summary := FormatSummary(item)
-		userSummaries[item.Username] = append(userSummaries[item.Username], summary)
-		userDurations[item.Username] += item.Duration
+// writeSections writes the comparison, if any, and the optional sections
+// enabled for s.
+func writeSections(b *strings.Builder, s *Summary, m markup) {
+	writeComparison(b, s.Comparison, m)
+	if s.hasSection(SectionStreams) {
+		writeStreamHealth(b, s.Streams, m)
 	}
-
-	var builder strings.Builder
-
-	if totalLive > 0 {
-		builder.WriteString(fmt.Sprintf("📡 You watched %s of Live TV\n", formatDuration(totalLive)))
-		for show, dur := range liveByShow {
-			builder.WriteString(fmt.Sprintf("  %s: %s\n", show, formatDuration(dur)))
-		}
-		builder.WriteString("\n")
+	if s.hasSection(SectionDevices) {
+		writeDevices(b, s.Devices, m)
+	}
+	if s.hasSection(SectionProgress) {
+		writeProgress(b, s.Progress, m)
 	}
+	if s.hasSection(SectionCompletion) {
+		writeCompletion(b, s.Completion, m)
+	}
+	if s.hasSection(SectionCalendar) {
+		writeCalendar(b, s.Calendar, m)
+	}
+}
 
-	for user, lines := range userSummaries {
-		totalDur := formatDuration(userDurations[user])
-		builder.WriteString(fmt.Sprintf("%s (%s):\n", user, totalDur))
-		for _, line := range lines {
-			builder.WriteString(line)
+// writeMusic writes the user's listening grouped by artist and album.
+func writeMusic(b *strings.Builder, user *UserSummary, m markup) {
+	if len(user.Music) == 0 {
+		return
+	}
+	b.WriteString(fmt.Sprintf("🎵 Music (%d artists):\n", len(user.Music)))
+	for _, artist := range user.Music {
+		albums := make([]string, len(artist.Albums))
+		for i, album := range artist.Albums {
+			albums[i] = fmt.Sprintf("%s (%d)", m.escape(album.Title), album.Tracks)
 		}
-		builder.WriteString("\n")
+		b.WriteString(fmt.Sprintf("  - %s (%d tracks): %s\n", m.escape(artist.Artist), artist.Tracks, strings.Join(albums, ", ")))
 	}
-
-	builder.WriteString(fmt.Sprintf("🕒 Total duration: %s\n", data.TotalDuration))
-
-	return builder.String()
+	tracks, dur := user.MusicDuration()
+	b.WriteString(fmt.Sprintf("  Total: %d tracks — %s\n", tracks, m.code(formatDuration(dur))))
 }
 
+// FormatSummary renders a single history item as a plain text line.
 func FormatSummary(item HistoryItem) string {
-	t := time.Unix(item.Date, 0).Local().Format("15:04:05")
-
-	var status string
-	switch {
-	case item.WatchedStatus >= 0.95:
-		status = "Complete"
-	case item.WatchedStatus <= 0.05:
-		status = "Unwatched"
-	default:
-		status = fmt.Sprintf("%d%% Watched", int(item.WatchedStatus*100))
-	}
+	return formatItem(item, textMarkup)
+}
 
+func formatItem(item HistoryItem, m markup) string {
+	t := time.Unix(item.Date, 0).In(AppConfig.Location).Format("15:04:05")
 	minutes := item.Duration / 60
 
 	epInfo := ""
-	if item.MediaType == "episode" && item.Season != 0 && item.Episode != 0 {
-		epInfo = fmt.Sprintf(" S%dE%d", item.Season, item.Episode)
+	if code := episodeCode(item); code != "" {
+		epInfo = " " + code
 	}
 
 	prefix := "  "
@@ -190,18 +265,43 @@ func FormatSummary(item HistoryItem) string {
 		prefix = "▶️ "
 	}
 
-	return fmt.Sprintf("%s%s %s%s for ~%d min [%s] @ %s (%s)\n",
+	rewatch := ""
+	if item.Rewatch {
+		rewatch = " 🔁"
+	}
+
+	return fmt.Sprintf("%s%s %s%s%s for ~%d min [%s] %s\n",
 		prefix,
 		MediaIcon(item),
-		item.Title,
+		m.escape(item.Title),
 		epInfo,
+		rewatch,
 		minutes,
-		status,
-		t,
-		item.Player,
+		watchedStatus(item),
+		m.italic(fmt.Sprintf("@ %s (%s)", t, m.escape(item.Player))),
 	)
 }
 
+// watchedStatus describes how much of the item was watched.
+func watchedStatus(item HistoryItem) string {
+	switch {
+	case item.WatchedStatus >= completeThreshold:
+		return "Complete"
+	case item.WatchedStatus <= 0.05:
+		return "Unwatched"
+	default:
+		return fmt.Sprintf("%d%% Watched", int(item.WatchedStatus*100))
+	}
+}
+
+// episodeCode returns "S1E2" for episodes with known numbers.
+func episodeCode(item HistoryItem) string {
+	if item.MediaType == "episode" && item.Season != 0 && item.Episode != 0 {
+		return fmt.Sprintf("S%dE%d", item.Season, item.Episode)
+	}
+	return ""
+}
+
 func MediaIcon(item HistoryItem) string {
 	switch {
 	case item.Live == 1:
@@ -210,6 +310,8 @@ func MediaIcon(item HistoryItem) string {
 		return "📺"
 	case item.MediaType == "movie":
 		return "🎬"
+	case item.MediaType == "track":
+		return "🎵"
 	default:
 		return "❓"
 	}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// fixtureHistory covers two users with movies, a binge, single episodes
+// and music, plus live TV, in an order that differs from the sorted one.
+func fixtureHistory() *HistoryData {
+	day := time.Date(2024, 5, 1, 18, 0, 0, 0, time.UTC).Unix()
+	episode := func(user string, episode int, offset int64) HistoryItem {
+		return HistoryItem{
+			Username: user, UserID: 2, Title: "Severance - Episode", MediaType: "episode",
+			GrandparentTitle: "Severance", Season: 2, Episode: FlexInt(episode),
+			Date: day + offset, Stopped: day + offset + 3000, Duration: 3000, WatchedStatus: 1,
+			Player: "Living Room", Platform: "tvOS", Product: "Plex for Apple TV", TranscodeDecision: "direct play",
+		}
+	}
+	return &HistoryData{
+		TotalDuration: "5 hrs 30 mins",
+		History: []HistoryItem{
+			{
+				Username: "bob", UserID: 3, Title: "Tom & Jerry <Special> *Cut*", MediaType: "movie",
+				Date: day + 600, Stopped: day + 6000, Duration: 5400, WatchedStatus: 0.5,
+				Player: "Chrome", Platform: "Windows", Product: "Plex Web", TranscodeDecision: "transcode",
+			},
+			episode("alice", 1, 0),
+			episode("alice", 2, 3100),
+			episode("alice", 3, 6200),
+			{
+				Username: "alice", UserID: 2, Title: "Heat", MediaType: "movie",
+				Date: day - 20000, Stopped: day - 12000, Duration: 8000, WatchedStatus: 1,
+				Player: "Living Room", Platform: "tvOS", Product: "Plex for Apple TV", TranscodeDecision: "direct play",
+			},
+			{
+				Username: "bob", UserID: 3, Title: "Song", MediaType: "track",
+				GrandparentTitle: "Artist_One", ParentTitle: "Album [Deluxe]",
+				Date: day - 3000, Stopped: day - 2800, Duration: 200, WatchedStatus: 1,
+				Player: "Phone", Platform: "Android", Product: "Plexamp", TranscodeDecision: "direct play",
+			},
+			{
+				Username: "bob", UserID: 3, Title: "Evening News", MediaType: "episode", Live: 1,
+				GrandparentTitle: "News", Date: day + 9000, Stopped: day + 10800, Duration: 1800,
+				Player: "Chrome", Platform: "Windows", Product: "Plex Web", TranscodeDecision: "transcode",
+			},
+		},
+	}
+}
+
+func TestRenderSummaryGolden(t *testing.T) {
+	saved := AppConfig
+	defer func() { AppConfig = saved }()
+	AppConfig.Location = time.UTC
+	AppConfig.SortBy = SortWatchTime
+	AppConfig.BingeGap = 30 * time.Minute
+	AppConfig.BingeMinEpisodes = 3
+	AppConfig.Sections = []string{SectionStreams, SectionDevices}
+
+	for _, layout := range []string{LayoutDaily, LayoutAggregated} {
+		for _, format := range []string{FormatText, FormatMarkdown, FormatHTML} {
+			name := layout + "." + templateExtensions[format]
+			t.Run(name, func(t *testing.T) {
+				summary := buildSummary(fixtureHistory())
+				summary.Period = "2024-05-01"
+				got, err := renderSummary(summary, format, layout == LayoutAggregated)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				path := filepath.Join("testdata", name+".golden")
+				if *update {
+					if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+						t.Fatal(err)
+					}
+					return
+				}
+				want, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatalf("%v (run go test -update to create it)", err)
+				}
+				if got != string(want) {
+					t.Errorf("output differs from %s:\n--- got ---\n%s\n--- want ---\n%s", path, got, want)
+				}
+			})
+		}
+	}
+}
@@ -6,12 +6,27 @@ import (
 	"net/http"
 )
 
+type gotifyNotifier struct{}
+
+func (gotifyNotifier) Name() string { return "gotify" }
+
+func (gotifyNotifier) Format() string { return AppConfig.GotifyFormat }
+
+func (gotifyNotifier) Notify(title, message string) error {
+	return sendToGotify(title, message)
+}
+
 func sendToGotify(title, message string) error {
 	payload := map[string]interface{}{
 		"title":    title,
 		"message":  message,
 		"priority": 5,
 	}
+	if AppConfig.GotifyFormat == FormatMarkdown {
+		payload["extras"] = map[string]interface{}{
+			"client::display": map[string]string{"contentType": "text/markdown"},
+		}
+	}
 	jsonData, _ := json.Marshal(payload)
 
 	req, err := http.NewRequest("POST", AppConfig.GotifyURL+"/message", bytes.NewBuffer(jsonData))
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Leaderboard categories for /top.
+const (
+	TopUsers     = "users"
+	TopShows     = "shows"
+	TopMovies    = "movies"
+	TopDevices   = "devices"
+	TopRewatches = "rewatches"
+)
+
+var topCategories = []string{TopUsers, TopShows, TopMovies, TopDevices, TopRewatches}
+
+// leaderboardSize is how many places a leaderboard shows.
+const leaderboardSize = 10
+
+// Ranking is one place on a leaderboard. Entries with the same score share
+// a rank and the next rank is skipped ("1, 2, 2, 4").
+type Ranking struct {
+	Rank  int    `json:"rank"`
+	Name  string `json:"name"`
+	Score int    `json:"score"`
+}
+
+type Leaderboard struct {
+	Category  string    `json:"category"`
+	Period    string    `json:"period"`
+	WatchTime []Ranking `json:"watch_time"`
+	Plays     []Ranking `json:"plays"`
+}
+
+func buildLeaderboard(items []HistoryItem, category string) Leaderboard {
+	var usage usageSet
+	for _, item := range items {
+		switch category {
+		case TopUsers:
+			usage.add(item.Username, item.Duration)
+		case TopShows:
+			if item.MediaType == "episode" {
+				usage.add(showTitle(item), item.Duration)
+			}
+		case TopMovies:
+			if item.MediaType == "movie" {
+				usage.add(item.Title, item.Duration)
+			}
+		case TopDevices:
+			usage.add(deviceName(item), item.Duration)
+		case TopRewatches:
+			if item.Rewatch {
+				usage.add(item.Title, item.Duration)
+			}
+		}
+	}
+
+	return Leaderboard{
+		Category:  category,
+		WatchTime: rank(usage.groups, func(g *UsageGroup) int { return g.Duration }),
+		Plays:     rank(usage.groups, func(g *UsageGroup) int { return g.Plays }),
+	}
+}
+
+// rank orders groups by score, highest first, with ties ordered by name, and
+// keeps the first leaderboardSize places.
+func rank(groups []*UsageGroup, score func(*UsageGroup) int) []Ranking {
+	rankings := make([]Ranking, 0, len(groups))
+	for _, g := range groups {
+		if score(g) > 0 {
+			rankings = append(rankings, Ranking{Name: g.Name, Score: score(g)})
+		}
+	}
+	slices.SortFunc(rankings, func(a, b Ranking) int {
+		return cmp.Or(
+			cmp.Compare(b.Score, a.Score),
+			cmp.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)),
+			cmp.Compare(a.Name, b.Name),
+		)
+	})
+
+	for i := range rankings {
+		if i > 0 && rankings[i].Score == rankings[i-1].Score {
+			rankings[i].Rank = rankings[i-1].Rank
+		} else {
+			rankings[i].Rank = i + 1
+		}
+	}
+
+	// Cut after the last place, but never in the middle of a tie.
+	end := min(leaderboardSize, len(rankings))
+	for end < len(rankings) && rankings[end].Rank == rankings[end-1].Rank {
+		end++
+	}
+	return rankings[:end]
+}
+
+func renderLeaderboard(board Leaderboard, format string) (string, error) {
+	if format == FormatJSON {
+		return renderJSON(board)
+	}
+	m, err := markupFor(format)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("🏆 %s %s\n\n", m.bold("Top "+board.Category), m.italic(m.escape(board.Period))))
+	if len(board.WatchTime) == 0 {
+		b.WriteString("Nothing to rank.\n")
+		return b.String(), nil
+	}
+
+	b.WriteString(m.bold("⏱ By watch time") + "\n")
+	for _, r := range board.WatchTime {
+		b.WriteString(fmt.Sprintf("%s %s — %s\n", podium(r.Rank), m.escape(r.Name), m.code(formatDuration(r.Score))))
+	}
+	b.WriteString("\n" + m.bold("▶️ By plays") + "\n")
+	for _, r := range board.Plays {
+		b.WriteString(fmt.Sprintf("%s %s — %s\n", podium(r.Rank), m.escape(r.Name), m.code(plural(r.Score, "play"))))
+	}
+	return b.String(), nil
+}
+
+// plural returns "1 play" or "3 plays".
+func plural(n int, word string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, word)
+	}
+	return fmt.Sprintf("%d %ss", n, word)
+}
+
+// podium returns a medal for the first three ranks.
+func podium(rank int) string {
+	switch rank {
+	case 1:
+		return "🥇"
+	case 2:
+		return "🥈"
+	case 3:
+		return "🥉"
+	}
+	return fmt.Sprintf("%d.", rank)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// plays returns groups named a, b, c, … with the given play counts.
+func plays(counts ...int) []*UsageGroup {
+	groups := make([]*UsageGroup, len(counts))
+	for i, n := range counts {
+		groups[i] = &UsageGroup{Name: string(rune('a' + i)), Plays: n}
+	}
+	return groups
+}
+
+// places renders rankings as "rank name score" for compact comparison.
+func places(rankings []Ranking) []string {
+	var out []string
+	for _, r := range rankings {
+		out = append(out, fmt.Sprintf("%d %s %d", r.Rank, r.Name, r.Score))
+	}
+	return out
+}
+
+func TestRank(t *testing.T) {
+	tests := []struct {
+		name   string
+		groups []*UsageGroup
+		want   []string
+	}{
+		{
+			name:   "ties share a rank and skip the next",
+			groups: plays(5, 7, 5, 1),
+			want:   []string{"1 b 7", "2 a 5", "2 c 5", "4 d 1"},
+		},
+		{
+			name: "ties ordered by name ignoring case",
+			groups: []*UsageGroup{
+				{Name: "bob", Plays: 2}, {Name: "Alice", Plays: 2}, {Name: "alice", Plays: 2},
+			},
+			want: []string{"1 Alice 2", "1 alice 2", "1 bob 2"},
+		},
+		{
+			name:   "zero scores are left out",
+			groups: plays(0, 3, 0),
+			want:   []string{"1 b 3"},
+		},
+		{
+			name:   "cut after ten places",
+			groups: plays(12, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1),
+			want:   []string{"1 a 12", "2 b 11", "3 c 10", "4 d 9", "5 e 8", "6 f 7", "7 g 6", "8 h 5", "9 i 4", "10 j 3"},
+		},
+		{
+			name:   "tie at the cutoff is kept whole",
+			groups: plays(20, 19, 18, 17, 16, 15, 14, 13, 12, 1, 1, 1),
+			want:   []string{"1 a 20", "2 b 19", "3 c 18", "4 d 17", "5 e 16", "6 f 15", "7 g 14", "8 h 13", "9 i 12", "10 j 1", "10 k 1", "10 l 1"},
+		},
+		{
+			name:   "tie across the cutoff is kept whole",
+			groups: plays(20, 19, 18, 17, 16, 15, 14, 13, 2, 2, 2, 1),
+			want:   []string{"1 a 20", "2 b 19", "3 c 18", "4 d 17", "5 e 16", "6 f 15", "7 g 14", "8 h 13", "9 i 2", "9 j 2", "9 k 2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := places(rank(tt.groups, func(g *UsageGroup) int { return g.Plays }))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("rank = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
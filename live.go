@@ -0,0 +1,189 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// A run of at least surfMinPlays live plays, each shorter than
+// surfMaxPlay and at most surfMaxGap apart, counts as channel surfing.
+const (
+	surfMinPlays = 4
+	surfMaxPlay  = 5 * time.Minute
+	surfMaxGap   = 10 * time.Minute
+)
+
+// LiveStats breaks live TV down by user and channel.
+type LiveStats struct {
+	Period   string        `json:"period"`
+	Duration int           `json:"duration"`
+	Channels []*UsageGroup `json:"channels"`
+	Users    []*UserLive   `json:"users"`
+	Surfing  []*SurfRun    `json:"surfing"`
+}
+
+type UserLive struct {
+	Name     string        `json:"name"`
+	Plays    int           `json:"plays"`
+	Duration int           `json:"duration"`
+	Channels []*UsageGroup `json:"channels"`
+	Shows    []*UsageGroup `json:"shows"`
+}
+
+// SurfRun is a stretch of many short live plays by one user.
+type SurfRun struct {
+	User     string `json:"user"`
+	Start    int64  `json:"start"`
+	End      int64  `json:"end"`
+	Plays    int    `json:"plays"`
+	Channels int    `json:"channels"`
+}
+
+// liveChannel names the channel of a live play by its call sign, falling
+// back to the channel identifier.
+func liveChannel(item HistoryItem) string {
+	switch {
+	case item.ChannelCallSign != "":
+		return item.ChannelCallSign
+	case item.ChannelIdentifier != "":
+		return item.ChannelIdentifier
+	}
+	return "Unknown channel"
+}
+
+func buildLiveStats(items []HistoryItem) *LiveStats {
+	l := &LiveStats{}
+	var channels usageSet
+	shows := make(map[string]*usageSet)
+	userChannels := make(map[string]*usageSet)
+	index := make(map[string]*UserLive)
+	plays := make(map[string][]HistoryItem)
+
+	for _, item := range items {
+		if item.Live != 1 {
+			continue
+		}
+		l.Duration += item.Duration
+		channels.add(liveChannel(item), item.Duration)
+
+		u := index[item.Username]
+		if u == nil {
+			u = &UserLive{Name: item.Username}
+			index[item.Username] = u
+			l.Users = append(l.Users, u)
+			shows[item.Username] = &usageSet{}
+			userChannels[item.Username] = &usageSet{}
+		}
+		u.Plays++
+		u.Duration += item.Duration
+		shows[item.Username].add(showTitle(item), item.Duration)
+		userChannels[item.Username].add(liveChannel(item), item.Duration)
+		plays[item.Username] = append(plays[item.Username], item)
+	}
+
+	l.Channels = channels.sorted()
+	for _, u := range l.Users {
+		u.Shows = shows[u.Name].sorted()
+		u.Channels = userChannels[u.Name].sorted()
+		l.Surfing = append(l.Surfing, findSurfing(plays[u.Name])...)
+	}
+	sortGroups(l.Users, SortWatchTime, func(u *UserLive) (string, int, int64) { return u.Name, u.Duration, 0 })
+	slices.SortFunc(l.Surfing, func(a, b *SurfRun) int { return cmp.Compare(a.Start, b.Start) })
+	return l
+}
+
+// findSurfing returns the channel surfing runs in one user's live plays.
+func findSurfing(items []HistoryItem) []*SurfRun {
+	slices.SortFunc(items, func(a, b HistoryItem) int { return cmp.Compare(a.Date, b.Date) })
+
+	var runs []*SurfRun
+	var run []HistoryItem
+	flush := func() {
+		if len(run) >= surfMinPlays {
+			// Only known channels count; plays without one may be anything.
+			var seen []string
+			for _, item := range run {
+				ch := item.ChannelCallSign + item.ChannelIdentifier
+				if ch != "" && !slices.Contains(seen, ch) {
+					seen = append(seen, ch)
+				}
+			}
+			last := run[len(run)-1]
+			runs = append(runs, &SurfRun{User: last.Username, Start: run[0].Date, End: itemEnd(last), Plays: len(run), Channels: len(seen)})
+		}
+		run = nil
+	}
+
+	for _, item := range items {
+		if time.Duration(item.Duration)*time.Second >= surfMaxPlay {
+			flush()
+			continue
+		}
+		if len(run) > 0 && item.Date-itemEnd(run[len(run)-1]) > int64(surfMaxGap.Seconds()) {
+			flush()
+		}
+		run = append(run, item)
+	}
+	flush()
+	return runs
+}
+
+func renderLive(l *LiveStats, format string) (string, error) {
+	if format == FormatJSON {
+		return renderJSON(l)
+	}
+	m, err := markupFor(format)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("📡 %s %s\n\n", m.bold("Live TV"), m.italic(m.escape(l.Period))))
+	if len(l.Users) == 0 {
+		b.WriteString("No live TV was watched.\n")
+		return b.String(), nil
+	}
+	b.WriteString(fmt.Sprintf("⏱ Total: %s\n\n", m.code(formatDuration(l.Duration))))
+
+	b.WriteString(m.bold("📺 By channel") + "\n")
+	for _, ch := range l.Channels {
+		b.WriteString(fmt.Sprintf("  %s: %s, %s\n", m.escape(ch.Name), m.code(formatDuration(ch.Duration)), plural(ch.Plays, "play")))
+	}
+
+	b.WriteString("\n" + m.bold("👤 By user") + "\n")
+	for _, u := range l.Users {
+		b.WriteString(fmt.Sprintf("  %s: %s, %s\n", m.escape(u.Name), m.code(formatDuration(u.Duration)), plural(u.Plays, "play")))
+		b.WriteString(fmt.Sprintf("    Channels: %s\n", usageList(u.Channels, m)))
+		b.WriteString(fmt.Sprintf("    Shows: %s\n", usageList(u.Shows, m)))
+	}
+
+	if len(l.Surfing) > 0 {
+		b.WriteString("\n" + m.bold("🏄 Channel surfing") + "\n")
+		for _, r := range l.Surfing {
+			start := time.Unix(r.Start, 0).In(AppConfig.Location)
+			channels := ""
+			if r.Channels > 0 {
+				channels = " across " + plural(r.Channels, "channel")
+			}
+			b.WriteString(fmt.Sprintf("  %s: %s%s in %s %s\n",
+				m.escape(r.User), plural(r.Plays, "play"), channels,
+				m.code(formatDuration(int(r.End-r.Start))), m.italic(start.Format("2006-01-02 15:04"))))
+		}
+	}
+	return b.String(), nil
+}
+
+// usageList joins the first few groups as "Name (1h 5m)".
+func usageList(groups []*UsageGroup, m markup) string {
+	var parts []string
+	for _, g := range topGroups(groups, 3) {
+		parts = append(parts, fmt.Sprintf("%s (%s)", m.escape(g.Name), formatDuration(g.Duration)))
+	}
+	if len(groups) > 3 {
+		parts = append(parts, fmt.Sprintf("+%d more", len(groups)-3))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestFindSurfing(t *testing.T) {
+	const base = 1714593600 // 2024-05-01 20:00 UTC
+	// zap is a live play of channel starting at the given second offset.
+	zap := func(channel string, start, seconds int) HistoryItem {
+		date := base + int64(start)
+		return HistoryItem{Username: "alice", ChannelCallSign: channel, Date: date, Stopped: date + int64(seconds), Duration: seconds}
+	}
+	// zaps are plays of the channels, each 60s long and gap seconds apart.
+	zaps := func(offset, gap int, channels ...string) []HistoryItem {
+		var items []HistoryItem
+		for i, channel := range channels {
+			items = append(items, zap(channel, offset+i*(60+gap), 60))
+		}
+		return items
+	}
+
+	tests := []struct {
+		name  string
+		items []HistoryItem
+		want  []string // "plays/channels" per run
+	}{
+		{"gaps at the window", zaps(0, 600, "ORF1", "ORF2", "ORF3", "ARD"), []string{"4/4"}},
+		{"gap just over the window", append(zaps(0, 0, "ORF1", "ORF2", "ORF3"), zap("ARD", 180+601, 60)), nil},
+		{"too few plays", zaps(0, 0, "ORF1", "ORF2", "ORF3"), nil},
+		{"unsorted plays", append(zaps(300, 0, "ORF3", "ARD"), zaps(0, 60, "ORF1", "ORF2")...), []string{"4/4"}},
+		{"channels counted once, unknown ones not at all", zaps(0, 0, "ORF1", "", "ORF1", "ORF2", ""), []string{"5/2"}},
+		{
+			name:  "a play at the length limit ends the run",
+			items: []HistoryItem{zap("ORF1", 0, 60), zap("ORF2", 60, 60), zap("ORF3", 120, 300), zap("ARD", 420, 60), zap("ZDF", 480, 60)},
+		},
+		{
+			name:  "a long play splits runs",
+			items: append(append(zaps(0, 0, "ORF1", "ORF2", "ORF3", "ARD"), zap("ZDF", 240, 3600)), zaps(3840, 0, "ORF1", "ORF2", "ORF3", "ARD")...),
+			want:  []string{"4/4", "4/4"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, run := range findSurfing(tt.items) {
+				got = append(got, fmt.Sprintf("%d/%d", run.Plays, run.Channels))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("runs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindSurfingSpan(t *testing.T) {
+	items := []HistoryItem{
+		{Username: "alice", ChannelCallSign: "ORF1", Date: 1000, Stopped: 1060, Duration: 60},
+		{Username: "alice", ChannelCallSign: "ORF2", Date: 1100, Stopped: 1130, Duration: 30},
+		{Username: "alice", ChannelCallSign: "ORF3", Date: 1200, Stopped: 1290, Duration: 90},
+		{Username: "alice", ChannelCallSign: "ARD", Date: 1300, Duration: 120},
+	}
+	runs := findSurfing(items)
+	if len(runs) != 1 {
+		t.Fatalf("got %d runs, want 1", len(runs))
+	}
+	if r := runs[0]; r.User != "alice" || r.Start != 1000 || r.End != 1420 {
+		t.Errorf("run = %+v, want alice from 1000 to 1420", r)
+	}
+}
@@ -1,37 +1,168 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/robfig/cron/v3"
 )
 
 var shouldRunOnce = flag.Bool("run-once", false, "Run summary and exit")
 var runDate = flag.String("date", "", "Run summary for a specific date (YYYY-MM-DD)")
+var runUser = flag.String("user", "", "Only include this user's activity (with -run-once or -wrapped)")
+var wrappedYear = flag.Int("wrapped", 0, "Print the year-in-review recap for a year (YYYY) and exit")
+var outputFormat = flag.String("format", FormatText, "Output format for -wrapped: text, markdown, html or json")
+var outputFile = flag.String("out", "", "Write -wrapped output to this file instead of stdout")
 
 func main() {
 	flag.Parse()
 	LoadConfig()
 
+	if *wrappedYear != 0 {
+		runWrapped(*wrappedYear, *runUser, *outputFormat, *outputFile)
+		return
+	}
+
 	if *shouldRunOnce {
-		runOnce(*runDate)
+		runOnce(*runDate, *runUser)
 		return
 	}
 
-	StartScheduler()
-	StartTelegramBot()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	run(ctx)
+}
+
+// run starts every subsystem enabled by the configuration and blocks until
+// ctx is cancelled, then shuts them down.
+func run(ctx context.Context) {
+	var bot *tgbotapi.BotAPI
+	if AppConfig.TelegramEnabled() {
+		var err error
+		bot, err = newTelegramBot()
+		if err != nil {
+			log.Fatal("Telegram error: ", err)
+		}
+	} else {
+		log.Println("No TELEGRAM_TOKEN set — Telegram bot disabled.")
+	}
+
+	notifiers := buildNotifiers(bot)
+
+	var wg sync.WaitGroup
+	if bot != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runTelegramBot(ctx, bot)
+		}()
+	}
+
+	var scheduler *cron.Cron
+	if !AppConfig.SchedulerEnabled() {
+		log.Println("No DAILY_SUMMARY_SCHEDULE or REPORTS set — scheduler disabled.")
+	} else if len(notifiers) == 0 {
+		log.Println("No notifier configured (Gotify or TELEGRAM_NOTIFY_CHATS) — scheduler disabled.")
+	} else {
+		scheduler = StartScheduler(notifiers)
+	}
+
+	var srv *http.Server
+	if AppConfig.HTTPEnabled() {
+		srv = StartHTTPServer()
+	}
+
+	if bot == nil && scheduler == nil && srv == nil {
+		log.Fatal("Nothing to run: enable the Telegram bot, the scheduler or the HTTP server")
+	}
+
+	<-ctx.Done()
+	log.Println("Shutting down...")
+
+	if scheduler != nil {
+		<-scheduler.Stop().Done()
+	}
+	if srv != nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Println("HTTP shutdown error:", err)
+		}
+	}
+	wg.Wait()
 }
 
-func runOnce(dateArg string) {
+func runOnce(dateArg, userArg string) {
+	var bot *tgbotapi.BotAPI
+	if AppConfig.TelegramEnabled() && len(AppConfig.TelegramNotifyChats) > 0 {
+		var err error
+		bot, err = newTelegramBot()
+		if err != nil {
+			log.Fatal("Telegram error: ", err)
+		}
+	}
+	notifiers := buildNotifiers(bot)
+	if len(notifiers) == 0 {
+		log.Fatal("No notifier configured: set GOTIFY_URL/GOTIFY_TOKEN or TELEGRAM_NOTIFY_CHATS")
+	}
+
 	if dateArg == "" {
-		dateArg = time.Now().AddDate(0, 0, -1).Format(dateLayout)
+		dateArg = now().AddDate(0, 0, -1).Format(dateLayout)
 	}
-	history, err := fetchAllHistoryForDate(dateArg)
+	opts := HistoryRequest{StartDate: dateArg}
+	if userArg != "" {
+		user, err := findUser(userArg)
+		if err != nil {
+			log.Fatal("User error: ", err)
+		}
+		opts.UserID = user.UserID
+	}
+
+	history, err := fetchAllHistory(opts)
 	if err != nil {
 		log.Fatal("Fetch error:", err)
 	}
-	summary := generateSummary(history, false)
-	if err := sendToGotify("📅 Plex summary", summary); err != nil {
-		log.Fatal("Gotify error:", err)
+	summary := buildSummary(history)
+	summary.Period = dateArg
+	message := noteMessage(noActivityText)
+	if !summary.Empty() {
+		summary.loadSections(opts.End(now()))
+		summary.loadRewatches()
+		message = summaryMessage(summary, false)
+	}
+	if err := notifyAll(notifiers, "📅 Plex summary", message); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func runWrapped(year int, userArg, format, out string) {
+	wrapped, err := fetchWrapped(year, userArg)
+	if err != nil {
+		log.Fatal("Wrapped error: ", err)
+	}
+	text, err := renderWrapped(wrapped, format)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if format == FormatHTML {
+		text = htmlDocument(fmt.Sprintf("Plex Wrapped %d", year), text)
+	}
+
+	if out == "" {
+		fmt.Print(text)
+		return
+	}
+	if err := os.WriteFile(out, []byte(text), 0o644); err != nil {
+		log.Fatal(err)
 	}
 }
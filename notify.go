@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Notifier delivers a finished summary to an external service.
+type Notifier interface {
+	Name() string
+	// Format is the summary format the service displays, one of
+	// summaryFormats.
+	Format() string
+	Notify(title, message string) error
+}
+
+// renderFunc produces the message body in the requested format, so every
+// notifier receives the summary in the format it displays.
+type renderFunc func(format string) (string, error)
+
+// summaryMessage renders s for each notifier.
+func summaryMessage(s *Summary, compressed bool) renderFunc {
+	return func(format string) (string, error) {
+		return renderSummary(s, format, compressed)
+	}
+}
+
+// noteMessage sends a fixed line of text, escaped for the notifier's format.
+func noteMessage(text string) renderFunc {
+	return func(format string) (string, error) {
+		switch format {
+		case FormatHTML:
+			return htmlMarkup.escape(text), nil
+		case FormatMarkdown:
+			return markdownMarkup.escape(text), nil
+		}
+		return text, nil
+	}
+}
+
+// buildNotifiers returns every notifier enabled by the configuration. bot may
+// be nil when the Telegram bot is disabled.
+func buildNotifiers(bot *tgbotapi.BotAPI) []Notifier {
+	var notifiers []Notifier
+	if AppConfig.GotifyEnabled() {
+		notifiers = append(notifiers, gotifyNotifier{})
+	}
+	if bot != nil && len(AppConfig.TelegramNotifyChats) > 0 {
+		notifiers = append(notifiers, telegramNotifier{bot: bot, chats: AppConfig.TelegramNotifyChats})
+	}
+	return notifiers
+}
+
+// notifyAll sends the message to all notifiers and reports every failure
+// instead of stopping at the first one.
+func notifyAll(notifiers []Notifier, title string, render renderFunc) error {
+	if failed := notifyEach(notifiers, title, render); len(failed) > 0 {
+		return fmt.Errorf("delivery failed for: %s", notifierNames(failed))
+	}
+	return nil
+}
+
+// notifyEach sends the message to all notifiers and returns the ones that
+// failed.
+func notifyEach(notifiers []Notifier, title string, render renderFunc) []Notifier {
+	var failed []Notifier
+	for _, n := range notifiers {
+		message, err := render(n.Format())
+		if err == nil {
+			err = n.Notify(title, message)
+		}
+		if err != nil {
+			log.Printf("%s error: %v", n.Name(), err)
+			failed = append(failed, n)
+		}
+	}
+	return failed
+}
+
+func notifierNames(notifiers []Notifier) string {
+	names := make([]string, len(notifiers))
+	for i, n := range notifiers {
+		names[i] = n.Name()
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// peakBarWidth is the length of the longest histogram bar.
+const peakBarWidth = 12
+
+// Peak is the highest number of simultaneous streams per day and when in
+// the day and week watch time falls.
+type Peak struct {
+	Period string     `json:"period"`
+	Max    DayPeak    `json:"max"`
+	Days   []*DayPeak `json:"days"`
+	// Hours and Weekdays hold seconds watched per hour of day and per
+	// weekday, Monday first.
+	Hours    [24]int `json:"hours"`
+	Weekdays [7]int  `json:"weekdays"`
+}
+
+// DayPeak is the moment of a day with the most streams running at once.
+type DayPeak struct {
+	Day     string `json:"day"`
+	Streams int    `json:"streams"`
+	// Transcodes is how many of those streams were transcoded.
+	Transcodes int   `json:"transcodes"`
+	At         int64 `json:"at"`
+}
+
+type streamEvent struct {
+	at        int64
+	delta     int
+	transcode bool
+}
+
+// buildPeak sweeps the plays' start and stop times in order. A stream that
+// stops at the same second another starts does not overlap it.
+func buildPeak(items []HistoryItem) *Peak {
+	p := &Peak{}
+	var events []streamEvent
+	for _, item := range items {
+		end := itemEnd(item)
+		if end <= item.Date {
+			continue
+		}
+		transcode := item.TranscodeDecision == "transcode"
+		events = append(events, streamEvent{item.Date, 1, transcode}, streamEvent{end, -1, transcode})
+		p.addWatchTime(item.Date, item.Date+int64(item.Duration))
+	}
+	slices.SortFunc(events, func(a, b streamEvent) int {
+		return cmp.Or(cmp.Compare(a.at, b.at), cmp.Compare(a.delta, b.delta))
+	})
+
+	index := make(map[string]*DayPeak)
+	var streams, transcodes int
+	for _, e := range events {
+		streams += e.delta
+		if e.transcode {
+			transcodes += e.delta
+		}
+		if e.delta < 0 {
+			continue
+		}
+		day := time.Unix(e.at, 0).In(AppConfig.Location).Format(dateLayout)
+		d := index[day]
+		if d == nil {
+			d = &DayPeak{Day: day}
+			index[day] = d
+			p.Days = append(p.Days, d)
+		}
+		if streams > d.Streams {
+			d.Streams, d.Transcodes, d.At = streams, transcodes, e.at
+		}
+		if streams > p.Max.Streams {
+			p.Max = *d
+		}
+	}
+	return p
+}
+
+// addWatchTime spreads the seconds from start to end over the hours and
+// weekdays they fall into. It steps in elapsed time rather than with
+// time.Date so the repeated hour of a DST switch is counted in full.
+func (p *Peak) addWatchTime(start, end int64) {
+	t := time.Unix(start, 0).In(AppConfig.Location)
+	for t.Unix() < end {
+		next := t.Add(time.Hour - time.Duration(t.Minute())*time.Minute - time.Duration(t.Second())*time.Second)
+		seconds := min(next.Unix(), end) - t.Unix()
+		p.Hours[t.Hour()] += int(seconds)
+		p.Weekdays[(int(t.Weekday())+6)%7] += int(seconds)
+		t = next
+	}
+}
+
+func renderPeak(p *Peak, format string) (string, error) {
+	if format == FormatJSON {
+		return renderJSON(p)
+	}
+	m, err := markupFor(format)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("📈 %s %s\n\n", m.bold("Peak usage"), m.italic(m.escape(p.Period))))
+	if len(p.Days) == 0 {
+		b.WriteString("Nothing was streamed.\n")
+		return b.String(), nil
+	}
+
+	b.WriteString(fmt.Sprintf("🔝 Most at once: %s on %s at %s%s\n\n",
+		m.code(plural(p.Max.Streams, "stream")), p.Max.Day, formatClock(p.Max.At), transcodeNote(p.Max)))
+
+	b.WriteString(m.bold("📅 Per day") + "\n")
+	for _, d := range p.Days {
+		b.WriteString(fmt.Sprintf("%s: %s at %s%s\n", d.Day, m.code(strconv.Itoa(d.Streams)), formatClock(d.At), transcodeNote(*d)))
+	}
+
+	b.WriteString("\n" + m.bold("🕒 By hour") + "\n")
+	hourMax := slices.Max(p.Hours[:])
+	for hour, seconds := range p.Hours {
+		if seconds == 0 {
+			continue
+		}
+		b.WriteString(fmt.Sprintf("%s %s %s\n", m.code(fmt.Sprintf("%02d", hour)), m.code(bar(seconds, hourMax)), formatDuration(seconds)))
+	}
+
+	b.WriteString("\n" + m.bold("📆 By weekday") + "\n")
+	weekdayMax := slices.Max(p.Weekdays[:])
+	for weekday, seconds := range p.Weekdays {
+		b.WriteString(fmt.Sprintf("%s %s %s\n", m.code(calendarWeekdays[weekday]), m.code(bar(seconds, weekdayMax)), formatDuration(seconds)))
+	}
+	return b.String(), nil
+}
+
+func transcodeNote(d DayPeak) string {
+	if d.Transcodes == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (%d transcoded)", d.Transcodes)
+}
+
+func formatClock(unix int64) string {
+	return time.Unix(unix, 0).In(AppConfig.Location).Format("15:04")
+}
+
+// bar draws value as a share of peakBarWidth, padded to the full width.
+func bar(value, maximum int) string {
+	n := 0
+	if maximum > 0 {
+		n = (value*peakBarWidth + maximum - 1) / maximum
+	}
+	return strings.Repeat("█", n) + strings.Repeat(" ", peakBarWidth-n)
+}
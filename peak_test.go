@@ -0,0 +1,147 @@
+package main
+
+import "testing"
+
+// stream is a play from start to stop, both "2006-01-02 15:04" in Vienna.
+func stream(t *testing.T, start, stop string, transcode bool) HistoryItem {
+	t.Helper()
+	from, to := at(t, start).Unix(), at(t, stop).Unix()
+	item := HistoryItem{Date: from, Stopped: to, Duration: int(to - from), TranscodeDecision: "direct play"}
+	if transcode {
+		item.TranscodeDecision = "transcode"
+	}
+	return item
+}
+
+func TestBuildPeak(t *testing.T) {
+	saved := AppConfig.Location
+	defer func() { AppConfig.Location = saved }()
+	AppConfig.Location = vienna(t)
+
+	tests := []struct {
+		name  string
+		items func(t *testing.T) []HistoryItem
+		want  DayPeak
+	}{
+		{
+			name: "back to back streams do not overlap",
+			items: func(t *testing.T) []HistoryItem {
+				return []HistoryItem{
+					stream(t, "2024-05-01 20:00", "2024-05-01 21:00", false),
+					stream(t, "2024-05-01 21:00", "2024-05-01 22:00", false),
+				}
+			},
+			want: DayPeak{Day: "2024-05-01", Streams: 1, At: at(t, "2024-05-01 20:00").Unix()},
+		},
+		{
+			name: "equal start times overlap",
+			items: func(t *testing.T) []HistoryItem {
+				return []HistoryItem{
+					stream(t, "2024-05-01 20:00", "2024-05-01 20:30", false),
+					stream(t, "2024-05-01 20:00", "2024-05-01 21:00", true),
+				}
+			},
+			want: DayPeak{Day: "2024-05-01", Streams: 2, Transcodes: 1, At: at(t, "2024-05-01 20:00").Unix()},
+		},
+		{
+			name: "stop and start at the same time before a third",
+			items: func(t *testing.T) []HistoryItem {
+				return []HistoryItem{
+					stream(t, "2024-05-01 20:00", "2024-05-01 22:00", true),
+					stream(t, "2024-05-01 20:00", "2024-05-01 21:00", false),
+					stream(t, "2024-05-01 21:00", "2024-05-01 21:30", true),
+					stream(t, "2024-05-01 21:10", "2024-05-01 21:20", false),
+				}
+			},
+			want: DayPeak{Day: "2024-05-01", Streams: 3, Transcodes: 2, At: at(t, "2024-05-01 21:10").Unix()},
+		},
+		{
+			name: "plays without length are ignored",
+			items: func(t *testing.T) []HistoryItem {
+				return []HistoryItem{
+					stream(t, "2024-05-01 20:00", "2024-05-01 20:00", false),
+					stream(t, "2024-05-01 20:00", "2024-05-01 21:00", false),
+				}
+			},
+			want: DayPeak{Day: "2024-05-01", Streams: 1, At: at(t, "2024-05-01 20:00").Unix()},
+		},
+		{
+			name: "first day with the highest peak wins",
+			items: func(t *testing.T) []HistoryItem {
+				return []HistoryItem{
+					stream(t, "2024-05-01 20:00", "2024-05-01 21:00", false),
+					stream(t, "2024-05-01 20:30", "2024-05-01 21:00", false),
+					stream(t, "2024-05-02 20:00", "2024-05-02 21:00", false),
+					stream(t, "2024-05-02 20:30", "2024-05-02 21:00", false),
+				}
+			},
+			want: DayPeak{Day: "2024-05-01", Streams: 2, At: at(t, "2024-05-01 20:30").Unix()},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := buildPeak(tt.items(t)).Max; got != tt.want {
+				t.Errorf("Max = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildPeakDays(t *testing.T) {
+	saved := AppConfig.Location
+	defer func() { AppConfig.Location = saved }()
+	AppConfig.Location = vienna(t)
+
+	// The second stream runs past midnight and keeps counting on the next
+	// day, where the third one starts.
+	p := buildPeak([]HistoryItem{
+		stream(t, "2024-05-01 22:00", "2024-05-01 23:00", false),
+		stream(t, "2024-05-01 23:30", "2024-05-02 01:00", false),
+		stream(t, "2024-05-02 00:15", "2024-05-02 00:45", false),
+	})
+	var got []DayPeak
+	for _, d := range p.Days {
+		got = append(got, *d)
+	}
+	want := []DayPeak{
+		{Day: "2024-05-01", Streams: 1, At: at(t, "2024-05-01 22:00").Unix()},
+		{Day: "2024-05-02", Streams: 2, At: at(t, "2024-05-02 00:15").Unix()},
+	}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("Days = %+v, want %+v", got, want)
+	}
+}
+
+func TestPeakHistograms(t *testing.T) {
+	saved := AppConfig.Location
+	defer func() { AppConfig.Location = saved }()
+	AppConfig.Location = vienna(t)
+
+	tests := []struct {
+		name      string
+		start     string
+		stop      string
+		hours     map[int]int
+		weekday   int // Monday is 0
+		wantTotal int
+	}{
+		{"within an evening", "2024-05-01 20:30", "2024-05-01 22:15", map[int]int{20: 1800, 21: 3600, 22: 900}, 2, 6300},
+		{"over the spring switch", "2024-03-31 01:30", "2024-03-31 03:30", map[int]int{1: 1800, 3: 1800}, 6, 3600},
+		{"over the autumn switch", "2024-10-27 01:30", "2024-10-27 03:30", map[int]int{1: 1800, 2: 7200, 3: 1800}, 6, 10800},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := buildPeak([]HistoryItem{stream(t, tt.start, tt.stop, false)})
+			var total int
+			for hour, seconds := range p.Hours {
+				total += seconds
+				if seconds != tt.hours[hour] {
+					t.Errorf("Hours[%d] = %d, want %d", hour, seconds, tt.hours[hour])
+				}
+			}
+			if total != tt.wantTotal || p.Weekdays[tt.weekday] != tt.wantTotal {
+				t.Errorf("total %d, weekday %d = %d; want %d for both", total, tt.weekday, p.Weekdays[tt.weekday], tt.wantTotal)
+			}
+		})
+	}
+}
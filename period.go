@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	PeriodYesterday  = "yesterday"
+	PeriodLast7Days  = "last7days"
+	PeriodPrevWeek   = "prevweek"
+	PeriodPrevMonth  = "prevmonth"
+	PeriodYearToDate = "ytd"
+)
+
+var knownPeriods = []string{PeriodYesterday, PeriodLast7Days, PeriodPrevWeek, PeriodPrevMonth, PeriodYearToDate}
+
+// Period is an inclusive range of calendar days.
+type Period struct {
+	From time.Time
+	To   time.Time
+}
+
+// resolvePeriod returns the days covered by the named period when a report
+// runs at ref. Every period ends before the day ref falls on.
+func resolvePeriod(name string, ref time.Time) (Period, error) {
+	today := time.Date(ref.Year(), ref.Month(), ref.Day(), 0, 0, 0, 0, ref.Location())
+	yesterday := today.AddDate(0, 0, -1)
+
+	switch name {
+	case PeriodYesterday:
+		return Period{From: yesterday, To: yesterday}, nil
+	case PeriodLast7Days:
+		return Period{From: today.AddDate(0, 0, -7), To: yesterday}, nil
+	case PeriodPrevWeek:
+		// Weeks start on Monday.
+		offset := (int(today.Weekday()) + 6) % 7
+		monday := today.AddDate(0, 0, -offset)
+		return Period{From: monday.AddDate(0, 0, -7), To: monday.AddDate(0, 0, -1)}, nil
+	case PeriodPrevMonth:
+		first := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, today.Location())
+		return Period{From: first.AddDate(0, -1, 0), To: first.AddDate(0, 0, -1)}, nil
+	case PeriodYearToDate:
+		first := time.Date(today.Year(), 1, 1, 0, 0, 0, 0, today.Location())
+		if !yesterday.Before(first) {
+			return Period{From: first, To: yesterday}, nil
+		}
+		// On January 1st the year so far is empty, report the previous one.
+		return Period{From: first.AddDate(-1, 0, 0), To: yesterday}, nil
+	}
+	return Period{}, fmt.Errorf("unknown period %q", name)
+}
+
+// previous returns the equivalent period before p: the month before a
+// calendar month, the same dates a year earlier for a year-to-date period,
+// and otherwise the same number of days directly before.
+func (p Period) previous(name string) Period {
+	switch name {
+	case PeriodPrevMonth:
+		return Period{From: p.From.AddDate(0, -1, 0), To: p.From.AddDate(0, 0, -1)}
+	case PeriodYearToDate:
+		return Period{From: p.From.AddDate(-1, 0, 0), To: p.To.AddDate(-1, 0, 0)}
+	}
+	days := int(p.To.Sub(p.From).Hours()/24+0.5) + 1
+	return Period{From: p.From.AddDate(0, 0, -days), To: p.To.AddDate(0, 0, -days)}
+}
+
+// Request builds the history query for the period.
+func (p Period) Request() HistoryRequest {
+	if p.From.Equal(p.To) {
+		return HistoryRequest{StartDate: p.From.Format(dateLayout)}
+	}
+	return HistoryRequest{
+		AfterDate:  p.From.Format(dateLayout),
+		BeforeDate: p.To.Format(dateLayout),
+	}
+}
+
+func (p Period) String() string {
+	if p.From.Equal(p.To) {
+		return p.From.Format(dateLayout)
+	}
+	return p.From.Format(dateLayout) + " – " + p.To.Format(dateLayout)
+}
+
+// Period keywords accepted by commands in addition to knownPeriods.
+const (
+	PeriodToday    = "today"
+	PeriodLastWeek = "lastweek"
+	PeriodAll      = "all"
+)
+
+// parsePeriodArgs extracts a period from command arguments: a keyword
+// (today, yesterday, lastweek, all or a report period), a single date or a
+// "YYYY-MM-DD YYYY-MM-DD" range. Arguments that are not part of the period
+// are returned in order. Without a period, def is used. Multi-day ranges
+// longer than a week are compressed.
+func parsePeriodArgs(args []string, ref time.Time, def string) (HistoryRequest, []string, error) {
+	var rest, dates []string
+	keyword := ""
+	for _, arg := range args {
+		lower := strings.ToLower(arg)
+		switch {
+		case lower == PeriodToday || lower == PeriodLastWeek || lower == PeriodAll || slices.Contains(knownPeriods, lower):
+			if keyword != "" || len(dates) > 0 {
+				return HistoryRequest{}, nil, fmt.Errorf("more than one period given")
+			}
+			keyword = lower
+		case isDate(arg):
+			if keyword != "" || len(dates) == 2 {
+				return HistoryRequest{}, nil, fmt.Errorf("more than one period given")
+			}
+			dates = append(dates, arg)
+		default:
+			rest = append(rest, arg)
+		}
+	}
+
+	switch len(dates) {
+	case 1:
+		return HistoryRequest{StartDate: dates[0]}, rest, nil
+	case 2:
+		from, _ := time.Parse(dateLayout, dates[0])
+		to, _ := time.Parse(dateLayout, dates[1])
+		if to.Before(from) {
+			return HistoryRequest{}, nil, fmt.Errorf("range ends before it starts")
+		}
+		return HistoryRequest{AfterDate: dates[0], BeforeDate: dates[1], Compressed: true}, rest, nil
+	}
+
+	if keyword == "" {
+		keyword = def
+	}
+	req, err := keywordRequest(keyword, ref)
+	return req, rest, err
+}
+
+func keywordRequest(keyword string, ref time.Time) (HistoryRequest, error) {
+	switch keyword {
+	case PeriodToday:
+		return HistoryRequest{StartDate: ref.Format(dateLayout)}, nil
+	case PeriodLastWeek:
+		return HistoryRequest{AfterDate: ref.AddDate(0, 0, -7).Format(dateLayout)}, nil
+	case PeriodAll:
+		return HistoryRequest{AllTime: true, Compressed: true}, nil
+	}
+
+	period, err := resolvePeriod(keyword, ref)
+	if err != nil {
+		return HistoryRequest{}, err
+	}
+	req := period.Request()
+	req.Compressed = period.To.Sub(period.From) > 7*24*time.Hour
+	return req, nil
+}
+
+func isDate(s string) bool {
+	_, err := time.Parse(dateLayout, s)
+	return err == nil
+}
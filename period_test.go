@@ -0,0 +1,134 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// In Europe/Vienna 2024-03-31 has 23 hours and 2024-10-27 has 25.
+func vienna(t *testing.T) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation("Europe/Vienna")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func at(t *testing.T, value string) time.Time {
+	t.Helper()
+	ref, err := time.ParseInLocation("2006-01-02 15:04", value, vienna(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ref
+}
+
+func TestResolvePeriodAcrossDST(t *testing.T) {
+	tests := []struct {
+		name   string
+		period string
+		ref    string
+		want   string
+	}{
+		{"yesterday is the short day", PeriodYesterday, "2024-04-01 00:30", "2024-03-31"},
+		{"yesterday on the short day", PeriodYesterday, "2024-03-31 23:30", "2024-03-30"},
+		{"yesterday is the long day", PeriodYesterday, "2024-10-28 00:30", "2024-10-27"},
+		{"yesterday on the long day", PeriodYesterday, "2024-10-27 02:30", "2024-10-26"},
+		{"last 7 days over spring switch", PeriodLast7Days, "2024-04-02 08:00", "2024-03-26 – 2024-04-01"},
+		{"last 7 days over autumn switch", PeriodLast7Days, "2024-10-30 08:00", "2024-10-23 – 2024-10-29"},
+		{"previous week ending on spring switch", PeriodPrevWeek, "2024-04-03 12:00", "2024-03-25 – 2024-03-31"},
+		{"previous week ending on autumn switch", PeriodPrevWeek, "2024-10-28 00:10", "2024-10-21 – 2024-10-27"},
+		{"previous month with spring switch", PeriodPrevMonth, "2024-04-01 00:30", "2024-03-01 – 2024-03-31"},
+		{"previous month with autumn switch", PeriodPrevMonth, "2024-11-01 00:30", "2024-10-01 – 2024-10-31"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := resolvePeriod(tt.period, at(t, tt.ref))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := p.String(); got != tt.want {
+				t.Errorf("resolvePeriod(%s, %s) = %s, want %s", tt.period, tt.ref, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPreviousPeriodAcrossDST(t *testing.T) {
+	tests := []struct {
+		name   string
+		period string
+		ref    string
+		want   string
+	}{
+		{"day before the short day", PeriodYesterday, "2024-04-01 08:00", "2024-03-30"},
+		{"day before the long day", PeriodYesterday, "2024-10-28 08:00", "2024-10-26"},
+		{"week after spring switch", PeriodPrevWeek, "2024-04-08 08:00", "2024-03-25 – 2024-03-31"},
+		{"week after autumn switch", PeriodPrevWeek, "2024-11-04 08:00", "2024-10-21 – 2024-10-27"},
+		{"last 7 days over spring switch", PeriodLast7Days, "2024-04-02 08:00", "2024-03-19 – 2024-03-25"},
+		{"last 7 days after autumn switch", PeriodLast7Days, "2024-11-01 08:00", "2024-10-18 – 2024-10-24"},
+		{"month before April", PeriodPrevMonth, "2024-05-01 08:00", "2024-03-01 – 2024-03-31"},
+		{"month before November", PeriodPrevMonth, "2024-12-01 08:00", "2024-10-01 – 2024-10-31"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := resolvePeriod(tt.period, at(t, tt.ref))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := p.previous(tt.period).String(); got != tt.want {
+				t.Errorf("previous of %s at %s = %s, want %s", tt.period, tt.ref, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLastWeekKeywordAcrossDST(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{"2024-04-03 12:00", "2024-03-27"},
+		{"2024-04-01 00:30", "2024-03-25"},
+		{"2024-10-30 12:00", "2024-10-23"},
+		{"2024-10-27 23:30", "2024-10-20"},
+	}
+	for _, tt := range tests {
+		req, err := keywordRequest(PeriodLastWeek, at(t, tt.ref))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if req.AfterDate != tt.want {
+			t.Errorf("lastweek at %s starts %s, want %s", tt.ref, req.AfterDate, tt.want)
+		}
+	}
+}
+
+func TestCalendarPeriodAcrossDST(t *testing.T) {
+	tests := []struct {
+		weeks int
+		ref   string
+		want  string
+	}{
+		{2, "2024-04-02 08:00", "2024-03-25 – 2024-04-02"},
+		{1, "2024-03-31 23:30", "2024-03-25 – 2024-03-31"},
+		{4, "2024-10-30 08:00", "2024-10-07 – 2024-10-30"},
+		{1, "2024-10-27 02:30", "2024-10-21 – 2024-10-27"},
+	}
+	for _, tt := range tests {
+		if got := calendarPeriod(tt.weeks, at(t, tt.ref)).String(); got != tt.want {
+			t.Errorf("calendarPeriod(%d, %s) = %s, want %s", tt.weeks, tt.ref, got, tt.want)
+		}
+	}
+}
+
+func TestNowUsesTimezone(t *testing.T) {
+	saved := AppConfig.Location
+	defer func() { AppConfig.Location = saved }()
+
+	AppConfig.Location = vienna(t)
+	if got := now().Location(); got != AppConfig.Location {
+		t.Errorf("now() is in %s, want %s", got, AppConfig.Location)
+	}
+}
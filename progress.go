@@ -0,0 +1,289 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SeriesProgress is the furthest episode a user has watched of a show.
+type SeriesProgress struct {
+	User        string `json:"user"`
+	Show        string `json:"show"`
+	Season      int    `json:"season"`
+	Episode     int    `json:"episode"`
+	LastWatched int64  `json:"last_watched"`
+	// Behind is the number of episodes after this one, or -1 when unknown.
+	Behind int `json:"behind"`
+
+	showKey int
+}
+
+// buildProgress finds the highest season and episode watched per user and
+// show. Specials (season 0) and episodes without numbers are ignored.
+func buildProgress(items []HistoryItem) []*SeriesProgress {
+	var progress []*SeriesProgress
+	index := make(map[[2]string]*SeriesProgress)
+
+	for _, item := range items {
+		if item.MediaType != "episode" || item.Season <= 0 || item.Episode <= 0 {
+			continue
+		}
+		key := [2]string{item.Username, showTitle(item)}
+		p := index[key]
+		if p == nil {
+			p = &SeriesProgress{User: item.Username, Show: showTitle(item), Behind: -1}
+			index[key] = p
+			progress = append(progress, p)
+		}
+		season, episode := int(item.Season), int(item.Episode)
+		if season > p.Season || (season == p.Season && episode > p.Episode) {
+			p.Season, p.Episode = season, episode
+			p.showKey = int(item.GrandparentRatingKey)
+		}
+		p.LastWatched = max(p.LastWatched, item.Date)
+	}
+
+	slices.SortFunc(progress, func(a, b *SeriesProgress) int {
+		return cmp.Or(
+			cmp.Compare(strings.ToLower(a.User), strings.ToLower(b.User)),
+			cmp.Compare(b.LastWatched, a.LastWatched),
+		)
+	})
+	return progress
+}
+
+// lookupWorkers bounds the concurrent Tautulli lookups of one request.
+const lookupWorkers = 4
+
+// forEachParallel calls fn for every item, lookupWorkers at a time.
+func forEachParallel[T any](items []T, fn func(T)) {
+	sem := make(chan struct{}, lookupWorkers)
+	var wg sync.WaitGroup
+	for _, item := range items {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn(item)
+		}()
+	}
+	wg.Wait()
+}
+
+// fillBehind looks up every show's episode list to count how many episodes
+// each user still has to watch. Lookup failures leave Behind unknown.
+func fillBehind(progress []*SeriesProgress) {
+	shows := make(map[int]string)
+	var keys []int
+	for _, p := range progress {
+		if _, ok := shows[p.showKey]; !ok && p.showKey != 0 {
+			shows[p.showKey] = p.Show
+			keys = append(keys, p.showKey)
+		}
+	}
+
+	var mu sync.Mutex
+	lists := make(map[int][][2]int)
+	forEachParallel(keys, func(key int) {
+		episodes, err := showEpisodes(key)
+		if err != nil {
+			log.Printf("Episode list for %s failed: %v", shows[key], err)
+			return
+		}
+		mu.Lock()
+		lists[key] = episodes
+		mu.Unlock()
+	})
+
+	for _, p := range progress {
+		episodes, ok := lists[p.showKey]
+		if !ok {
+			continue
+		}
+		p.Behind = 0
+		for _, ep := range episodes {
+			if ep[0] > p.Season || (ep[0] == p.Season && ep[1] > p.Episode) {
+				p.Behind++
+			}
+		}
+	}
+}
+
+type ChildrenResponse struct {
+	Response struct {
+		Data struct {
+			Children []struct {
+				RatingKey        FlexInt `json:"rating_key"`
+				MediaIndex       FlexInt `json:"media_index"`
+				ParentMediaIndex FlexInt `json:"parent_media_index"`
+			} `json:"children_list"`
+		} `json:"data"`
+	} `json:"response"`
+}
+
+// episodeCacheTTL is how long a show's episode list is reused.
+const episodeCacheTTL = 6 * time.Hour
+
+type cachedEpisodes struct {
+	episodes [][2]int
+	fetched  time.Time
+}
+
+var (
+	episodeCacheMu sync.Mutex
+	episodeCache   = make(map[int]cachedEpisodes)
+)
+
+// showEpisodes returns the season and episode numbers of a show, without
+// specials.
+func showEpisodes(showKey int) ([][2]int, error) {
+	episodeCacheMu.Lock()
+	cached, ok := episodeCache[showKey]
+	episodeCacheMu.Unlock()
+	if ok && time.Since(cached.fetched) < episodeCacheTTL {
+		return cached.episodes, nil
+	}
+
+	seasons, err := fetchChildren(showKey, "show")
+	if err != nil {
+		return nil, err
+	}
+	var episodes [][2]int
+	for _, season := range seasons.Response.Data.Children {
+		if season.MediaIndex <= 0 {
+			continue
+		}
+		children, err := fetchChildren(int(season.RatingKey), "season")
+		if err != nil {
+			return nil, err
+		}
+		for _, ep := range children.Response.Data.Children {
+			episodes = append(episodes, [2]int{int(season.MediaIndex), int(ep.MediaIndex)})
+		}
+	}
+
+	episodeCacheMu.Lock()
+	episodeCache[showKey] = cachedEpisodes{episodes: episodes, fetched: time.Now()}
+	episodeCacheMu.Unlock()
+	return episodes, nil
+}
+
+func fetchChildren(ratingKey int, mediaType string) (*ChildrenResponse, error) {
+	url := fmt.Sprintf("%s/api/v2?apikey=%s&cmd=get_children_metadata&rating_key=%d&media_type=%s",
+		AppConfig.TautulliURL, AppConfig.APIKey, ratingKey, mediaType)
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result ChildrenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func writeProgress(b *strings.Builder, progress []*SeriesProgress, m markup) {
+	if len(progress) == 0 {
+		return
+	}
+	b.WriteString(fmt.Sprintf("📍 %s\n", m.bold("Progress")))
+	user := ""
+	for _, p := range progress {
+		if p.User != user {
+			user = p.User
+			b.WriteString(fmt.Sprintf("  👤 %s\n", m.escape(user)))
+		}
+		status := ""
+		switch {
+		case p.Behind == 0:
+			status = " (caught up)"
+		case p.Behind > 0:
+			status = fmt.Sprintf(" (%s behind latest)", plural(p.Behind, "episode"))
+		}
+		b.WriteString(fmt.Sprintf("    %s: %s%s\n", m.escape(p.Show), m.code(fmt.Sprintf("S%02dE%02d", p.Season, p.Episode)), status))
+	}
+	b.WriteString("\n")
+}
+
+func renderProgress(progress []*SeriesProgress, format string) (string, error) {
+	if format == FormatJSON {
+		return renderJSON(progress)
+	}
+	m, err := markupFor(format)
+	if err != nil {
+		return "", err
+	}
+	if len(progress) == 0 {
+		return "No shows watched yet.", nil
+	}
+	var b strings.Builder
+	writeProgress(&b, progress, m)
+	return b.String(), nil
+}
+
+// fetchShowProgress returns the all-time progress of every user and show
+// watched in items, so a short period still shows where everyone is.
+func fetchShowProgress(items []HistoryItem) []*SeriesProgress {
+	var episodes []HistoryItem
+	var keys []int
+	watched := make(map[[2]string]bool)
+	for _, item := range items {
+		if item.MediaType != "episode" {
+			continue
+		}
+		episodes = append(episodes, item)
+		watched[[2]string{item.Username, showTitle(item)}] = true
+		if key := int(item.GrandparentRatingKey); key != 0 && !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+
+	var mu sync.Mutex
+	forEachParallel(keys, func(key int) {
+		history, err := fetchAllHistory(HistoryRequest{AllTime: true, ShowKey: key})
+		if err != nil {
+			log.Printf("Show history for %d failed: %v", key, err)
+			return
+		}
+		mu.Lock()
+		episodes = append(episodes, history.History...)
+		mu.Unlock()
+	})
+
+	progress := slices.DeleteFunc(buildProgress(episodes), func(p *SeriesProgress) bool {
+		return !watched[[2]string{p.User, p.Show}]
+	})
+	fillBehind(progress)
+	return progress
+}
+
+// fetchProgress builds the all-time progress, for one user when userName
+// is set.
+func fetchProgress(userName string) ([]*SeriesProgress, error) {
+	opts := HistoryRequest{AllTime: true, MediaType: "episode"}
+	if userName != "" {
+		user, err := findUser(userName)
+		if err != nil {
+			return nil, err
+		}
+		opts.UserID = user.UserID
+	}
+
+	history, err := fetchAllHistory(opts)
+	if err != nil {
+		return nil, err
+	}
+	progress := buildProgress(history.History)
+	fillBehind(progress)
+	return progress, nil
+}
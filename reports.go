@@ -0,0 +1,264 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ReportJob is a named, scheduled summary configured through REPORT_<NAME>_*
+// environment variables.
+type ReportJob struct {
+	Name         string
+	Title        string
+	Schedule     string
+	Period       string
+	Compressed   bool
+	Users        []string
+	Destinations []string
+	EmptyPolicy  string
+	MediaType    string
+	Library      string
+	Sections     []string
+	Compare      bool
+}
+
+// Policies for periods without any activity.
+const (
+	EmptySend   = "send"   // send the summary as is
+	EmptySkip   = "skip"   // send nothing
+	EmptyNote   = "note"   // send a short "no activity" note
+	EmptyStreak = "streak" // send a note only when it ends a run of active reports
+)
+
+var emptyPolicies = []string{EmptySend, EmptySkip, EmptyNote, EmptyStreak}
+
+const noActivityText = "😴 No activity in this period."
+
+var reportNamePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// loadReportJobs reads the jobs listed in REPORTS. The legacy
+// DAILY_SUMMARY_SCHEDULE variable adds a detailed "daily" job for yesterday.
+func loadReportJobs() []ReportJob {
+	var jobs []ReportJob
+
+	if schedule := os.Getenv("DAILY_SUMMARY_SCHEDULE"); schedule != "" {
+		jobs = append(jobs, ReportJob{
+			Name:        "daily",
+			Title:       "📅 Daily Plex Summary",
+			Schedule:    schedule,
+			Period:      PeriodYesterday,
+			EmptyPolicy: EmptyNote,
+		})
+	}
+
+	for _, name := range splitList(os.Getenv("REPORTS")) {
+		name = strings.ToLower(name)
+		if !reportNamePattern.MatchString(name) {
+			log.Fatalf("Invalid report name %q: use lowercase letters, digits and underscores", name)
+		}
+		if slices.ContainsFunc(jobs, func(j ReportJob) bool { return j.Name == name }) {
+			log.Fatalf("Duplicate report name %q", name)
+		}
+		jobs = append(jobs, loadReportJob(name))
+	}
+	return jobs
+}
+
+func loadReportJob(name string) ReportJob {
+	env := func(key string) string {
+		return strings.TrimSpace(os.Getenv("REPORT_" + strings.ToUpper(name) + "_" + key))
+	}
+
+	job := ReportJob{
+		Name:         name,
+		Title:        env("TITLE"),
+		Schedule:     env("SCHEDULE"),
+		Period:       strings.ToLower(env("PERIOD")),
+		Users:        splitList(env("USERS")),
+		Destinations: splitList(strings.ToLower(env("DESTINATIONS"))),
+		EmptyPolicy:  strings.ToLower(env("EMPTY")),
+		Library:      env("LIBRARY"),
+	}
+
+	if job.Schedule == "" {
+		log.Fatalf("Report %q: REPORT_%s_SCHEDULE is required", name, strings.ToUpper(name))
+	}
+	if job.Period == "" {
+		job.Period = PeriodYesterday
+	}
+	if !slices.Contains(knownPeriods, job.Period) {
+		log.Fatalf("Report %q: unknown period %q (expected one of %s)", name, job.Period, strings.Join(knownPeriods, ", "))
+	}
+	if sections := env("SECTIONS"); sections != "" {
+		var err error
+		if job.Sections, err = parseSections(sections); err != nil {
+			log.Fatalf("Report %q: %v", name, err)
+		}
+	}
+	switch compare := strings.ToLower(env("COMPARE")); compare {
+	case "", "false", "no", "0":
+	case "true", "yes", "1":
+		job.Compare = true
+	default:
+		log.Fatalf("Report %q: invalid REPORT_%s_COMPARE %q (expected true or false)", name, strings.ToUpper(name), compare)
+	}
+	if mediaType := env("MEDIA_TYPE"); mediaType != "" {
+		var err error
+		if job.MediaType, err = parseMediaType(mediaType); err != nil {
+			log.Fatalf("Report %q: %v", name, err)
+		}
+	}
+	if job.EmptyPolicy == "" {
+		job.EmptyPolicy = EmptyNote
+	}
+	if !slices.Contains(emptyPolicies, job.EmptyPolicy) {
+		log.Fatalf("Report %q: unknown empty policy %q (expected one of %s)", name, job.EmptyPolicy, strings.Join(emptyPolicies, ", "))
+	}
+	switch format := strings.ToLower(env("FORMAT")); format {
+	case "", "detailed":
+	case "compressed":
+		job.Compressed = true
+	default:
+		log.Fatalf("Report %q: unknown format %q (expected detailed or compressed)", name, format)
+	}
+	if job.Title == "" {
+		job.Title = fmt.Sprintf("📅 Plex %s summary", name)
+	}
+	return job
+}
+
+// notifiersFor returns the job's destinations, or every notifier when the
+// job does not restrict them.
+func (j ReportJob) notifiersFor(all []Notifier) ([]Notifier, error) {
+	if len(j.Destinations) == 0 {
+		return all, nil
+	}
+	var selected []Notifier
+	for _, dest := range j.Destinations {
+		idx := slices.IndexFunc(all, func(n Notifier) bool { return n.Name() == dest })
+		if idx == -1 {
+			return nil, fmt.Errorf("report %q: destination %q is not configured", j.Name, dest)
+		}
+		selected = append(selected, all[idx])
+	}
+	return selected, nil
+}
+
+// runReport builds the job's summary for the period preceding ref and
+// delivers it according to the job's empty policy. Late runs are marked as
+// such in the title. It reports whether the period had any activity and
+// which notifiers failed; an error means nothing was sent.
+func runReport(job ReportJob, notifiers []Notifier, state *reportState, ref time.Time, late bool) (bool, []Notifier, error) {
+	period, err := resolvePeriod(job.Period, ref)
+	if err != nil {
+		return false, nil, err
+	}
+
+	summary, err := job.fetchSummary(period)
+	if err != nil {
+		return false, nil, err
+	}
+
+	empty := summary.Empty()
+	var message renderFunc
+	if empty {
+		switch job.EmptyPolicy {
+		case EmptySkip:
+			log.Printf("Report %q: no activity, skipped", job.Name)
+			return false, nil, nil
+		case EmptyNote:
+			message = noteMessage(noActivityText)
+		case EmptyStreak:
+			streak := state.streak(job.Name)
+			if streak == 0 {
+				log.Printf("Report %q: no activity, skipped", job.Name)
+				return false, nil, nil
+			}
+			message = noteMessage(fmt.Sprintf("🛑 Streak broken! After %d active reports in a row, nobody watched anything.", streak))
+		}
+	}
+
+	// Sections and rewatches are only looked up for a summary that is sent.
+	if message == nil {
+		summary.loadSections(period.To)
+		if !job.Compressed {
+			summary.loadRewatches()
+		}
+		if job.Compare && !empty {
+			previous, err := job.fetchSummary(period.previous(job.Period))
+			if err != nil {
+				return false, nil, err
+			}
+			summary.Comparison = buildComparison(summary, previous)
+		}
+		message = summaryMessage(summary, job.Compressed)
+	}
+
+	title := fmt.Sprintf("%s (%s)", job.Title, period)
+	if late {
+		title = "⏰ Late: " + title
+	}
+	return !empty, notifyEach(notifiers, title, message), nil
+}
+
+// fetchSummary builds the job's summary for period, applying its filters
+// and sections.
+func (j ReportJob) fetchSummary(period Period) (*Summary, error) {
+	opts := period.Request()
+	opts.MediaType = j.MediaType
+	if j.Library != "" {
+		// Resolved on every run so renamed or re-created libraries are found.
+		var err error
+		if opts.SectionID, err = findLibrary(j.Library); err != nil {
+			return nil, err
+		}
+	}
+
+	history, err := fetchAllHistory(opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(j.Users) > 0 {
+		history = filterUsers(history, j.Users)
+	}
+
+	summary := buildSummary(history)
+	summary.Period = period.String()
+	if j.Sections != nil {
+		summary.Sections = j.Sections
+	}
+	return summary, nil
+}
+
+// filterUsers keeps only the history of the given users and recomputes the
+// total duration accordingly.
+func filterUsers(data *HistoryData, users []string) *HistoryData {
+	var items []HistoryItem
+	var total int
+	for _, item := range data.History {
+		if slices.ContainsFunc(users, func(u string) bool { return strings.EqualFold(u, item.Username) }) {
+			items = append(items, item)
+			total += item.Duration
+		}
+	}
+	return &HistoryData{
+		History:       items,
+		TotalDuration: formatCustomDuration(time.Duration(total) * time.Second),
+		TotalRecords:  len(items),
+	}
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
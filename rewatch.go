@@ -0,0 +1,142 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+type rewatchKey struct {
+	userID, ratingKey int
+	date              int64
+}
+
+func rewatchKeyOf(item HistoryItem) rewatchKey {
+	return rewatchKey{item.UserID, int(item.RatingKey), item.Date}
+}
+
+// markRewatches flags movies and episodes a user had already finished
+// before. It needs each user's history up to the last day in items, looked
+// up in parallel and cached. Lookup failures leave that user's items
+// unflagged.
+func markRewatches(items []HistoryItem) {
+	last := make(map[int]int64)
+	for _, item := range items {
+		if item.RatingKey != 0 && (item.MediaType == "movie" || item.MediaType == "episode") {
+			last[item.UserID] = max(last[item.UserID], item.Date)
+		}
+	}
+	var users []int
+	for userID := range last {
+		users = append(users, userID)
+	}
+
+	var mu sync.Mutex
+	finished := make(map[int]map[int]int64)
+	forEachParallel(users, func(userID int) {
+		before := time.Unix(last[userID], 0).In(AppConfig.Location).Format(dateLayout)
+		firsts, err := userFinishes(userID, before)
+		if err != nil {
+			log.Printf("Rewatch lookup for user %d failed: %v", userID, err)
+			return
+		}
+		mu.Lock()
+		finished[userID] = firsts
+		mu.Unlock()
+	})
+
+	for i, item := range items {
+		if first, ok := finished[item.UserID][int(item.RatingKey)]; ok && first < item.Date {
+			items[i].Rewatch = true
+		}
+	}
+}
+
+// firstFinishes returns the earliest finished play per rating key.
+func firstFinishes(plays []HistoryItem) map[int]int64 {
+	firsts := make(map[int]int64)
+	for _, play := range plays {
+		if play.RatingKey == 0 || completion(play) < completeThreshold {
+			continue
+		}
+		if first, ok := firsts[int(play.RatingKey)]; !ok || play.Date < first {
+			firsts[int(play.RatingKey)] = play.Date
+		}
+	}
+	return firsts
+}
+
+// rewatchCacheTTL is how long a user's finished plays are reused.
+const rewatchCacheTTL = 6 * time.Hour
+
+type finishesKey struct {
+	userID int
+	before string
+}
+
+type cachedFinishes struct {
+	firsts  map[int]int64
+	fetched time.Time
+}
+
+var (
+	finishesCacheMu sync.Mutex
+	finishesCache   = make(map[finishesKey]cachedFinishes)
+)
+
+// userFinishes returns firstFinishes of the user's whole history up to
+// before, from the cache when possible.
+func userFinishes(userID int, before string) (map[int]int64, error) {
+	key := finishesKey{userID, before}
+	finishesCacheMu.Lock()
+	cached, ok := finishesCache[key]
+	finishesCacheMu.Unlock()
+	if ok && time.Since(cached.fetched) < rewatchCacheTTL {
+		return cached.firsts, nil
+	}
+
+	history, err := fetchAllHistory(HistoryRequest{BeforeDate: before, UserID: userID})
+	if err != nil {
+		return nil, err
+	}
+	firsts := firstFinishes(history.History)
+
+	finishesCacheMu.Lock()
+	// Entries are per day, so drop the expired ones instead of letting
+	// them pile up.
+	for k, c := range finishesCache {
+		if time.Since(c.fetched) >= rewatchCacheTTL {
+			delete(finishesCache, k)
+		}
+	}
+	finishesCache[key] = cachedFinishes{firsts: firsts, fetched: time.Now()}
+	finishesCacheMu.Unlock()
+	return firsts, nil
+}
+
+// loadRewatches flags rewatched items when MARK_REWATCHES is set. Only
+// detailed summaries show the markers, so compressed ones skip it.
+func (s *Summary) loadRewatches() {
+	if !AppConfig.MarkRewatches {
+		return
+	}
+	markRewatches(s.history)
+
+	// Users and sessions hold copies of the items.
+	rewatched := make(map[rewatchKey]bool)
+	for _, item := range s.history {
+		if item.Rewatch {
+			rewatched[rewatchKeyOf(item)] = true
+		}
+	}
+	for _, u := range s.Users {
+		for i := range u.Items {
+			u.Items[i].Rewatch = rewatched[rewatchKeyOf(u.Items[i])]
+		}
+		for _, session := range u.Sessions {
+			for i := range session.Items {
+				session.Items[i].Rewatch = rewatched[rewatchKeyOf(session.Items[i])]
+			}
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMarkRewatches(t *testing.T) {
+	saved := AppConfig.Location
+	defer func() { AppConfig.Location = saved }()
+	AppConfig.Location = time.UTC
+
+	day := time.Date(2024, 5, 10, 20, 0, 0, 0, time.UTC).Unix()
+	earlier := time.Date(2024, 1, 5, 20, 0, 0, 0, time.UTC).Unix()
+	play := func(ratingKey int, date int64, percent int) HistoryItem {
+		return HistoryItem{UserID: 2, RatingKey: FlexInt(ratingKey), MediaType: "movie", Date: date, PercentComplete: FlexInt(percent)}
+	}
+
+	tests := []struct {
+		name    string
+		history []HistoryItem // the user's history up to the summary day
+		item    HistoryItem
+		want    bool
+	}{
+		{
+			name:    "finished before",
+			history: []HistoryItem{play(10, earlier, 100), play(10, day, 100)},
+			item:    play(10, day, 100),
+			want:    true,
+		},
+		{
+			name:    "finished before by watched status",
+			history: []HistoryItem{{UserID: 2, RatingKey: 10, MediaType: "movie", Date: earlier, WatchedStatus: 1}},
+			item:    play(10, day, 40),
+			want:    true,
+		},
+		{
+			name:    "only partly watched before",
+			history: []HistoryItem{play(10, earlier, 60), play(10, day, 100)},
+			item:    play(10, day, 100),
+			want:    false,
+		},
+		{
+			name:    "first watch",
+			history: []HistoryItem{play(11, earlier, 100), play(10, day, 100)},
+			item:    play(10, day, 100),
+			want:    false,
+		},
+		{
+			name:    "finished later the same day",
+			history: []HistoryItem{play(10, day, 100), play(10, day+9000, 100)},
+			item:    play(10, day, 100),
+			want:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Seed the cache so no history is fetched.
+			key := finishesKey{2, "2024-05-10"}
+			finishesCacheMu.Lock()
+			finishesCache[key] = cachedFinishes{firsts: firstFinishes(tt.history), fetched: time.Now()}
+			finishesCacheMu.Unlock()
+			defer func() {
+				finishesCacheMu.Lock()
+				delete(finishesCache, key)
+				finishesCacheMu.Unlock()
+			}()
+
+			items := []HistoryItem{tt.item}
+			markRewatches(items)
+			if items[0].Rewatch != tt.want {
+				t.Errorf("Rewatch = %v, want %v", items[0].Rewatch, tt.want)
+			}
+			if line := formatItem(items[0], textMarkup); strings.Contains(line, "🔁") != tt.want {
+				t.Errorf("line %q, want 🔁 %v", line, tt.want)
+			}
+		})
+	}
+}
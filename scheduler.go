@@ -3,33 +3,180 @@ package main
 import (
 	"github.com/robfig/cron/v3"
 	"log"
+	"slices"
+	"sync"
 	"time"
 )
 
-func StartScheduler() {
-	if AppConfig.DailySummarySchedule == "" {
-		log.Println("No DAILY_SUMMARY_SCHEDULE set — scheduler disabled.")
-		return
+// StartScheduler registers every configured report job and returns the
+// running cron instance so it can be stopped on shutdown. When a state file
+// is configured, runs missed while the process was down are sent in the
+// background once the scheduler is running.
+func StartScheduler(notifiers []Notifier) *cron.Cron {
+	c := cron.New(cron.WithLocation(AppConfig.Location))
+
+	state := newReportState("")
+	if AppConfig.StateFile != "" {
+		var err error
+		state, err = loadReportState(AppConfig.StateFile)
+		if err != nil {
+			log.Fatalf("Failed to load state file %s: %v", AppConfig.StateFile, err)
+		}
 	}
 
-	c := cron.New()
+	var jobs []*scheduledJob
+	for _, job := range AppConfig.Reports {
+		targets, err := job.notifiersFor(notifiers)
+		if err != nil {
+			log.Fatal(err)
+		}
 
-	_, err := c.AddFunc(AppConfig.DailySummarySchedule, func() {
-		date := time.Now().AddDate(0, 0, -1).Format(dateLayout)
-		history, err := fetchAllHistoryForDate(date)
+		schedule, err := cron.ParseStandard(job.Schedule)
 		if err != nil {
-			log.Println("Scheduler error:", err)
-			return
+			log.Fatalf("Invalid cron schedule for report %q: %v", job.Name, err)
+		}
+
+		j := newScheduledJob(job, schedule, targets, state)
+		j.init(now())
+		jobs = append(jobs, j)
+
+		c.Schedule(schedule, cron.FuncJob(func() {
+			j.catchUp(now(), true)
+		}))
+		log.Printf("Scheduled report %q (%s) with schedule: %s", job.Name, job.Period, job.Schedule)
+	}
+
+	c.Start()
+	for _, j := range jobs {
+		go j.catchUp(now(), false)
+	}
+	return c
+}
+
+// reportRunner runs a report for ref and returns whether it had activity
+// and the targets that failed, see runReport.
+type reportRunner func(targets []Notifier, ref time.Time, late bool) (bool, []Notifier, error)
+
+// scheduledJob delivers the runs of a report job. Its mutex keeps a cron
+// fire from racing the startup catch-up.
+type scheduledJob struct {
+	mu       sync.Mutex
+	job      ReportJob
+	schedule cron.Schedule
+	targets  []Notifier
+	state    *reportState
+	run      reportRunner
+}
+
+func newScheduledJob(job ReportJob, schedule cron.Schedule, targets []Notifier, state *reportState) *scheduledJob {
+	return &scheduledJob{
+		job:      job,
+		schedule: schedule,
+		targets:  targets,
+		state:    state,
+		run: func(targets []Notifier, ref time.Time, late bool) (bool, []Notifier, error) {
+			return runReport(job, targets, state, ref, late)
+		},
+	}
+}
+
+// init records current as the starting point of destinations that have not
+// received a run yet, so nothing before it is caught up.
+func (j *scheduledJob) init(current time.Time) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, target := range j.targets {
+		if _, ok := j.state.lastDelivery(j.job.Name, target.Name()); ok {
+			continue
+		}
+		if err := j.state.markDelivered(j.job.Name, target.Name(), current); err != nil {
+			log.Printf("Report %q: failed to save state: %v", j.job.Name, err)
+		}
+	}
+}
+
+// catchUp sends every run due at or before current to the destinations that
+// have not received it, oldest first, so failed deliveries are retried on
+// the next run or start. Runs older than CatchupLookback are skipped. When
+// onTime is set, the latest run is the one the schedule just fired for and
+// is not titled as late.
+func (j *scheduledJob) catchUp(current time.Time, onTime bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	last := make(map[string]time.Time)
+	var earliest time.Time
+	for _, target := range j.targets {
+		t, ok := j.state.lastDelivery(j.job.Name, target.Name())
+		if !ok {
+			continue
+		}
+		last[target.Name()] = t
+		if earliest.IsZero() || t.Before(earliest) {
+			earliest = t
+		}
+	}
+	if earliest.IsZero() {
+		return
+	}
+
+	var due []time.Time
+	for t := j.schedule.Next(earliest.In(AppConfig.Location)); !t.After(current); t = j.schedule.Next(t) {
+		due = append(due, t)
+	}
+
+	// A destination that fails a run gets no later ones until the retry
+	// succeeds, so its last delivery never skips past a failed run.
+	stalled := make(map[string]bool)
+	oldest := current.Add(-AppConfig.CatchupLookback)
+	for i, t := range due {
+		var pending []Notifier
+		for _, target := range j.targets {
+			if l, ok := last[target.Name()]; ok && l.Before(t) && !stalled[target.Name()] {
+				pending = append(pending, target)
+			}
+		}
+		if len(pending) == 0 {
+			continue
 		}
-		summary := generateSummary(history, false)
-		if err := sendToGotify("📅 Daily Plex Summary", summary); err != nil {
-			log.Println("Gotify error:", err)
+		if t.Before(oldest) {
+			log.Printf("Report %q: skipping missed run at %s for %s (older than %s)", j.job.Name, t.Format(time.RFC3339), notifierNames(pending), AppConfig.CatchupLookback)
+			continue
 		}
-	})
+
+		late := !onTime || i < len(due)-1
+		if late {
+			log.Printf("Report %q: catching up missed run at %s for %s", j.job.Name, t.Format(time.RFC3339), notifierNames(pending))
+		}
+		for _, target := range j.deliver(pending, t, late) {
+			stalled[target.Name()] = true
+		}
+	}
+}
+
+// deliver runs the job for ref and records the delivery for every target
+// that received it. It returns the targets that did not, which the next
+// catchUp retries.
+func (j *scheduledJob) deliver(targets []Notifier, ref time.Time, late bool) []Notifier {
+	active, failed, err := j.run(targets, ref, late)
 	if err != nil {
-		log.Fatalf("Invalid cron schedule: %v", err)
+		log.Printf("Report %q error: %v, retrying on the next run", j.job.Name, err)
+		return targets
+	}
+	if len(failed) > 0 {
+		log.Printf("Report %q: delivery of the run at %s failed for %s, retrying on the next run", j.job.Name, ref.Format(time.RFC3339), notifierNames(failed))
 	}
 
-	log.Printf("Scheduler started with schedule: %s", AppConfig.DailySummarySchedule)
-	c.Start()
+	for _, target := range targets {
+		if slices.ContainsFunc(failed, func(n Notifier) bool { return n.Name() == target.Name() }) {
+			continue
+		}
+		if err := j.state.markDelivered(j.job.Name, target.Name(), ref); err != nil {
+			log.Printf("Report %q: failed to save state: %v", j.job.Name, err)
+		}
+	}
+	if err := j.state.markRun(j.job.Name, ref, active); err != nil {
+		log.Printf("Report %q: failed to save state: %v", j.job.Name, err)
+	}
+	return failed
 }
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+type fakeNotifier string
+
+func (n fakeNotifier) Name() string { return string(n) }
+
+func (fakeNotifier) Format() string { return FormatText }
+
+func (fakeNotifier) Notify(title, message string) error { return nil }
+
+// deliveredRun is one call of the stubbed report runner.
+type deliveredRun struct {
+	at      string
+	targets string
+	late    bool
+}
+
+// testJob returns a daily 08:00 job with an in-memory state whose runs are
+// recorded in runs. fail decides which targets fail a run; it may be nil.
+func testJob(t *testing.T, targets []Notifier, runs *[]deliveredRun, fail func(ref time.Time, targets []Notifier) ([]Notifier, error)) *scheduledJob {
+	t.Helper()
+	saved := AppConfig
+	t.Cleanup(func() { AppConfig = saved })
+	AppConfig.Location = time.UTC
+	AppConfig.CatchupLookback = 72 * time.Hour
+
+	schedule, err := cron.ParseStandard("0 8 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	j := newScheduledJob(ReportJob{Name: "daily"}, schedule, targets, newReportState(""))
+	j.run = func(targets []Notifier, ref time.Time, late bool) (bool, []Notifier, error) {
+		*runs = append(*runs, deliveredRun{ref.Format("01-02 15:04"), notifierNames(targets), late})
+		if fail == nil {
+			return true, nil, nil
+		}
+		failed, err := fail(ref, targets)
+		return true, failed, err
+	}
+	return j
+}
+
+func utc(t *testing.T, value string) time.Time {
+	t.Helper()
+	ref, err := time.Parse("2006-01-02 15:04", value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ref
+}
+
+func TestCatchUpFirstRunOnlyRecordsStart(t *testing.T) {
+	var runs []deliveredRun
+	j := testJob(t, []Notifier{fakeNotifier("gotify")}, &runs, nil)
+
+	current := utc(t, "2024-05-08 09:30")
+	j.init(current)
+	j.catchUp(current, false)
+
+	if len(runs) != 0 {
+		t.Errorf("first start sent %v, want nothing", runs)
+	}
+	if got, ok := j.state.lastDelivery("daily", "gotify"); !ok || !got.Equal(current) {
+		t.Errorf("last delivery = %v, %v; want %v", got, ok, current)
+	}
+	if _, ok := j.state.lastRun("daily"); ok {
+		t.Error("first start recorded a run")
+	}
+}
+
+func TestCatchUpSkipsRunsBeyondLookback(t *testing.T) {
+	var runs []deliveredRun
+	j := testJob(t, []Notifier{fakeNotifier("gotify")}, &runs, nil)
+	// A state file from before per-destination tracking.
+	if err := j.state.markRun("daily", utc(t, "2024-05-01 08:00"), true); err != nil {
+		t.Fatal(err)
+	}
+
+	current := utc(t, "2024-05-08 09:30")
+	j.init(current)
+	j.catchUp(current, false)
+
+	want := []deliveredRun{
+		{"05-06 08:00", "gotify", true},
+		{"05-07 08:00", "gotify", true},
+		{"05-08 08:00", "gotify", true},
+	}
+	if !slices.Equal(runs, want) {
+		t.Errorf("runs = %v, want %v", runs, want)
+	}
+	scheduled := utc(t, "2024-05-08 08:00")
+	if got, _ := j.state.lastRun("daily"); !got.Equal(scheduled) {
+		t.Errorf("last run = %v, want the scheduled time %v", got, scheduled)
+	}
+	if got, _ := j.state.lastDelivery("daily", "gotify"); !got.Equal(scheduled) {
+		t.Errorf("last delivery = %v, want %v", got, scheduled)
+	}
+	if got := j.state.streak("daily"); got != 4 {
+		t.Errorf("streak = %d, want 4", got)
+	}
+}
+
+func TestCatchUpRetriesFailedDestination(t *testing.T) {
+	var runs []deliveredRun
+	broken := true
+	j := testJob(t, []Notifier{fakeNotifier("gotify"), fakeNotifier("telegram")}, &runs, func(ref time.Time, targets []Notifier) ([]Notifier, error) {
+		if !broken {
+			return nil, nil
+		}
+		return slices.DeleteFunc(slices.Clone(targets), func(n Notifier) bool { return n.Name() != "telegram" }), nil
+	})
+	j.init(utc(t, "2024-05-01 09:00"))
+
+	j.catchUp(utc(t, "2024-05-02 08:00"), true)
+	j.catchUp(utc(t, "2024-05-03 08:00"), true)
+	broken = false
+	j.catchUp(utc(t, "2024-05-04 08:00"), true)
+
+	want := []deliveredRun{
+		{"05-02 08:00", "gotify, telegram", false},
+		{"05-02 08:00", "telegram", true},
+		{"05-03 08:00", "gotify", false},
+		{"05-02 08:00", "telegram", true},
+		{"05-03 08:00", "telegram", true},
+		{"05-04 08:00", "gotify, telegram", false},
+	}
+	if !slices.Equal(runs, want) {
+		t.Errorf("runs = %v, want %v", runs, want)
+	}
+	for _, dest := range []string{"gotify", "telegram"} {
+		if got, _ := j.state.lastDelivery("daily", dest); !got.Equal(utc(t, "2024-05-04 08:00")) {
+			t.Errorf("%s last delivery = %v, want 05-04 08:00", dest, got)
+		}
+	}
+}
+
+func TestCatchUpRetriesFailedRun(t *testing.T) {
+	var runs []deliveredRun
+	down := true
+	j := testJob(t, []Notifier{fakeNotifier("gotify")}, &runs, func(time.Time, []Notifier) ([]Notifier, error) {
+		if down {
+			return nil, errors.New("tautulli unreachable")
+		}
+		return nil, nil
+	})
+	j.init(utc(t, "2024-05-01 09:00"))
+
+	j.catchUp(utc(t, "2024-05-02 08:00"), true)
+	if _, ok := j.state.lastRun("daily"); ok {
+		t.Error("a failed run was recorded")
+	}
+	down = false
+	j.catchUp(utc(t, "2024-05-03 08:00"), true)
+
+	want := []deliveredRun{
+		{"05-02 08:00", "gotify", false},
+		{"05-02 08:00", "gotify", true},
+		{"05-03 08:00", "gotify", false},
+	}
+	if !slices.Equal(runs, want) {
+		t.Errorf("runs = %v, want %v", runs, want)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"time"
+)
+
+// StartHTTPServer serves a health check and on-demand summaries. The returned
+// server is already listening in the background.
+func StartHTTPServer() *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("/summary", handleSummary)
+
+	srv := &http.Server{
+		Addr:              AppConfig.HTTPListenAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("HTTP server error: %v", err)
+		}
+	}()
+
+	log.Printf("HTTP server listening on %s", AppConfig.HTTPListenAddr)
+	return srv
+}
+
+var contentTypes = map[string]string{
+	FormatText:     "text/plain; charset=utf-8",
+	FormatMarkdown: "text/markdown; charset=utf-8",
+	FormatHTML:     "text/html; charset=utf-8",
+	FormatJSON:     "application/json",
+}
+
+// handleSummary renders the summary for ?date=YYYY-MM-DD, defaulting to
+// yesterday, in the format given by ?format= (text, markdown, html or json),
+// optionally for a single ?user=.
+func handleSummary(w http.ResponseWriter, r *http.Request) {
+	date := r.URL.Query().Get("date")
+	if date == "" {
+		date = now().AddDate(0, 0, -1).Format(dateLayout)
+	}
+	if _, err := time.Parse(dateLayout, date); err != nil {
+		http.Error(w, "invalid date (expected YYYY-MM-DD)", http.StatusBadRequest)
+		return
+	}
+
+	opts := HistoryRequest{StartDate: date}
+	if name := r.URL.Query().Get("user"); name != "" {
+		users, err := fetchUsers()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		user, err := matchUser(users, name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		opts.UserID = user.UserID
+	}
+
+	history, err := fetchAllHistory(opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = FormatText
+	}
+	summary := buildSummary(history)
+	summary.Period = date
+	summary.loadSections(opts.End(now()))
+	body, err := renderSummary(summary, format, r.URL.Query().Get("compressed") != "")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if format == FormatHTML {
+		body = htmlDocument("Plex summary "+date, body)
+	}
+	w.Header().Set("Content-Type", contentTypes[format])
+	_, _ = w.Write([]byte(body))
+}
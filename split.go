@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// telegramMaxLen is the maximum length of a single Telegram message, counted
+// in UTF-16 code units of the visible text.
+const telegramMaxLen = 4096
+
+// pageMarkerReserve is the room kept free for a "\n(12/34)" page marker.
+const pageMarkerReserve = 10
+
+// splitMessage splits s into messages of at most maxLen UTF-16 code units of
+// visible text, which is how Telegram measures messages. Cuts prefer
+// paragraph breaks, so user sections stay together where they fit, then
+// line breaks, then spaces, and never fall inside a rune. With html set,
+// tags do not count towards the length, cuts never fall inside a tag or
+// entity, and tags open at a cut are closed and reopened in the next
+// message. When more than one message is needed, each gets a "(1/3)" marker.
+func splitMessage(s string, maxLen int, html bool) []string {
+	if messageLen(s, html) <= maxLen {
+		if s == "" {
+			return nil
+		}
+		return []string{s}
+	}
+
+	budget := maxLen - pageMarkerReserve
+	var parts []string
+	for s != "" {
+		tokens := tokenize(s, html)
+		chunk, rest := splitTokens(s, tokens, budget)
+
+		var open []string
+		if html {
+			open = openTags(chunk)
+			chunk += closingTags(open)
+		}
+		parts = append(parts, chunk)
+
+		// Only closing tags or line breaks left: nothing worth another message.
+		rest = strings.TrimLeft(rest, "\n")
+		if messageLen(rest, html) == 0 {
+			break
+		}
+		s = strings.Join(open, "") + rest
+	}
+
+	for i := range parts {
+		parts[i] += fmt.Sprintf("\n(%d/%d)", i+1, len(parts))
+	}
+	return parts
+}
+
+// token is a rune, tag or entity of a message with its visible length.
+type token struct {
+	start, end int
+	units      int
+}
+
+func tokenize(s string, html bool) []token {
+	var tokens []token
+	for i := 0; i < len(s); {
+		if html && s[i] == '<' {
+			if gt := strings.IndexByte(s[i:], '>'); gt >= 0 {
+				tokens = append(tokens, token{start: i, end: i + gt + 1})
+				i += gt + 1
+				continue
+			}
+		}
+		if html && s[i] == '&' {
+			if semi := strings.IndexByte(s[i:], ';'); semi > 0 && semi <= 10 {
+				tokens = append(tokens, token{start: i, end: i + semi + 1, units: 1})
+				i += semi + 1
+				continue
+			}
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		tokens = append(tokens, token{start: i, end: i + size, units: utf16.RuneLen(r)})
+		i += size
+	}
+	return tokens
+}
+
+// messageLen returns the visible length of s in UTF-16 code units.
+func messageLen(s string, html bool) int {
+	var n int
+	for _, t := range tokenize(s, html) {
+		n += t.units
+	}
+	return n
+}
+
+// splitTokens returns the longest prefix of s that fits into budget and ends
+// at the best available break, and the remainder after that break.
+func splitTokens(s string, tokens []token, budget int) (chunk, rest string) {
+	var units, limit int
+	for limit = 0; limit < len(tokens); limit++ {
+		if units+tokens[limit].units > budget {
+			break
+		}
+		units += tokens[limit].units
+	}
+	if limit == len(tokens) {
+		return s, ""
+	}
+
+	is := func(i int, c byte) bool {
+		t := tokens[i]
+		return t.end-t.start == 1 && s[t.start] == c
+	}
+	// A break is only useful after the first visible token, otherwise the
+	// message would be empty.
+	firstVisible := len(tokens)
+	for j := range tokens {
+		if tokens[j].units > 0 && !is(j, '\n') {
+			firstVisible = j
+			break
+		}
+	}
+	visibleBefore := func(i int) bool { return firstVisible < i }
+
+	for i := limit - 1; i > 0; i-- {
+		if is(i, '\n') && is(i-1, '\n') && visibleBefore(i-1) {
+			return s[:tokens[i-1].start], s[tokens[i].end:]
+		}
+	}
+	for i := limit - 1; i > 0; i-- {
+		if is(i, '\n') && visibleBefore(i) {
+			return s[:tokens[i].start], s[tokens[i].end:]
+		}
+	}
+	for i := limit - 1; i > 0; i-- {
+		if is(i, ' ') && visibleBefore(i) {
+			return s[:tokens[i].start], s[tokens[i].end:]
+		}
+	}
+
+	// No break fits: cut between tokens, but always make progress.
+	if limit == 0 || !visibleBefore(limit) {
+		for limit < len(tokens) && tokens[limit].units == 0 {
+			limit++
+		}
+		limit = min(limit+1, len(tokens))
+	}
+	if limit == len(tokens) {
+		return s, ""
+	}
+	return s[:tokens[limit].start], s[tokens[limit].start:]
+}
+
+// openTags returns the opening tags in s that are not closed within s, in
+// the order they were opened.
+func openTags(s string) []string {
+	var stack []string
+	for {
+		lt := strings.Index(s, "<")
+		if lt == -1 {
+			return stack
+		}
+		gt := strings.Index(s[lt:], ">")
+		if gt == -1 {
+			return stack
+		}
+		tag := s[lt : lt+gt+1]
+		s = s[lt+gt+1:]
+
+		if strings.HasPrefix(tag, "</") {
+			name := tagName(tag)
+			for i := len(stack) - 1; i >= 0; i-- {
+				if tagName(stack[i]) == name {
+					stack = stack[:i]
+					break
+				}
+			}
+		} else if !strings.HasSuffix(tag, "/>") {
+			stack = append(stack, tag)
+		}
+	}
+}
+
+func closingTags(open []string) string {
+	var b strings.Builder
+	for i := len(open) - 1; i >= 0; i-- {
+		b.WriteString("</" + tagName(open[i]) + ">")
+	}
+	return b.String()
+}
+
+// tagName returns "b" for "<b>", "</b>" and `<a href="...">`.
+func tagName(tag string) string {
+	name := strings.TrimLeft(tag, "</")
+	if end := strings.IndexAny(name, " >/"); end >= 0 {
+		name = name[:end]
+	}
+	return strings.ToLower(name)
+}
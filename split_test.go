@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// bodies strips the page markers from parts, failing if one is missing or
+// a part is longer than maxLen.
+func bodies(t *testing.T, parts []string, maxLen int, html bool) []string {
+	t.Helper()
+	var out []string
+	for i, part := range parts {
+		if n := messageLen(part, html); n > maxLen {
+			t.Errorf("part %d is %d units long, limit %d", i+1, n, maxLen)
+		}
+		if !utf8.ValidString(part) {
+			t.Errorf("part %d is not valid UTF-8", i+1)
+		}
+		marker := fmt.Sprintf("\n(%d/%d)", i+1, len(parts))
+		body, ok := strings.CutSuffix(part, marker)
+		if !ok {
+			t.Fatalf("part %d lacks the %q marker: %q", i+1, marker, part)
+		}
+		out = append(out, body)
+	}
+	return out
+}
+
+func TestSplitMessageSurrogatePairAtBoundary(t *testing.T) {
+	budget := telegramMaxLen - pageMarkerReserve
+	tests := []struct {
+		name      string
+		prefix    int
+		wantFirst string
+	}{
+		// "😀" is two UTF-16 units and must not be split between them.
+		{"emoji ends exactly at the limit", budget - 2, "😀"},
+		{"emoji straddles the limit", budget - 1, "a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := strings.Repeat("a", tt.prefix) + "😀" + strings.Repeat("b", 100)
+			parts := bodies(t, splitMessage(s, telegramMaxLen, false), telegramMaxLen, false)
+			if len(parts) != 2 {
+				t.Fatalf("got %d parts, want 2", len(parts))
+			}
+			if !strings.HasSuffix(parts[0], tt.wantFirst) {
+				t.Errorf("first part ends with %q, want %q", parts[0][len(parts[0])-4:], tt.wantFirst)
+			}
+			if got := strings.Join(parts, ""); got != s {
+				t.Error("parts do not add up to the message")
+			}
+		})
+	}
+}
+
+func TestSplitMessageLongWord(t *testing.T) {
+	s := "short line\n" + strings.Repeat("x", 10000)
+	parts := bodies(t, splitMessage(s, telegramMaxLen, false), telegramMaxLen, false)
+	if len(parts) != 4 {
+		t.Fatalf("got %d parts, want 4", len(parts))
+	}
+	if parts[0] != "short line" {
+		t.Errorf("first part = %q, want the line before the word", parts[0])
+	}
+	if got := strings.Join(parts[1:], ""); got != strings.Repeat("x", 10000) {
+		t.Error("the word was not split without loss")
+	}
+}
+
+func TestSplitMessageReopensNestedTags(t *testing.T) {
+	s := "<b><i>" + strings.Repeat("word ", 2000) + "</i></b>\nafter"
+	parts := bodies(t, splitMessage(s, telegramMaxLen, true), telegramMaxLen, true)
+	if len(parts) < 3 {
+		t.Fatalf("got %d parts, want at least 3", len(parts))
+	}
+	for i, part := range parts {
+		if open := openTags(part); len(open) > 0 {
+			t.Errorf("part %d leaves %v open", i+1, open)
+		}
+		if i > 0 && i < len(parts)-1 && !strings.HasPrefix(part, "<b><i>") {
+			t.Errorf("part %d does not reopen <b><i>: %q", i+1, part[:20])
+		}
+	}
+	if last := parts[len(parts)-1]; !strings.HasSuffix(last, "</i></b>\nafter") {
+		t.Errorf("last part = %q, want the tags closed once before \"after\"", last)
+	}
+}
+
+func TestSplitMessageKeepsEntities(t *testing.T) {
+	s := strings.Repeat("&amp;", 5000)
+	parts := bodies(t, splitMessage(s, telegramMaxLen, true), telegramMaxLen, true)
+	if len(parts) != 2 {
+		t.Fatalf("got %d parts, want 2", len(parts))
+	}
+	for i, part := range parts {
+		if strings.Count(part, "&") != strings.Count(part, "&amp;") || strings.Count(part, ";") != strings.Count(part, "&amp;") {
+			t.Errorf("part %d cuts an entity", i+1)
+		}
+	}
+	// Each entity counts as one unit.
+	if n := messageLen(parts[0], true); n != telegramMaxLen-pageMarkerReserve {
+		t.Errorf("first part holds %d entities, want %d", n, telegramMaxLen-pageMarkerReserve)
+	}
+}
+
+func TestSplitMessagePageMarkers(t *testing.T) {
+	if parts := splitMessage("fits", 100, false); len(parts) != 1 || parts[0] != "fits" {
+		t.Errorf("a message that fits = %q, want it unchanged without marker", parts)
+	}
+
+	// A budget of 20 leaves 10 units per page once the marker is reserved.
+	s := strings.Repeat("abcd ", 50)
+	parts := splitMessage(s, 20, false)
+	if len(parts) < 10 {
+		t.Fatalf("got %d parts, want at least 10 so markers have two digits", len(parts))
+	}
+	for i, body := range bodies(t, parts, 20, false) {
+		if n := messageLen(body, false); n > 20-pageMarkerReserve {
+			t.Errorf("part %d body is %d units, only %d fit next to the marker", i+1, n, 20-pageMarkerReserve)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+// reportState remembers when each report job last ran and which run each of
+// its destinations last received, so that missed or failed runs can be
+// caught up, and how many runs in a row had activity. Without a path it is
+// kept in memory only.
+type reportState struct {
+	mu      sync.Mutex
+	path    string
+	LastRun map[string]time.Time `json:"last_run"`
+	Streak  map[string]int       `json:"streak"`
+	// Delivered is keyed by "<job>/<destination>".
+	Delivered map[string]time.Time `json:"delivered"`
+}
+
+func newReportState(path string) *reportState {
+	return &reportState{
+		path:      path,
+		LastRun:   make(map[string]time.Time),
+		Streak:    make(map[string]int),
+		Delivered: make(map[string]time.Time),
+	}
+}
+
+func loadReportState(path string) (*reportState, error) {
+	state := newReportState(path)
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, err
+	}
+	if state.LastRun == nil {
+		state.LastRun = make(map[string]time.Time)
+	}
+	if state.Streak == nil {
+		state.Streak = make(map[string]int)
+	}
+	if state.Delivered == nil {
+		state.Delivered = make(map[string]time.Time)
+	}
+	return state, nil
+}
+
+func (s *reportState) lastRun(job string) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.LastRun[job]
+	return t, ok
+}
+
+// streak returns the number of consecutive deliveries of job that had
+// activity.
+func (s *reportState) streak(job string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Streak[job]
+}
+
+// lastDelivery returns the last run dest received of job. State files
+// written before deliveries were tracked per destination fall back to the
+// job's last run.
+func (s *reportState) lastDelivery(job, dest string) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t, ok := s.Delivered[job+"/"+dest]; ok {
+		return t, true
+	}
+	t, ok := s.LastRun[job]
+	return t, ok
+}
+
+// markRun records a run of job and writes the state file atomically. Runs
+// older than the last one, such as retries, do not change the streak.
+func (s *reportState) markRun(job string, t time.Time, active bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if last, ok := s.LastRun[job]; ok && !t.After(last) {
+		return nil
+	}
+	s.LastRun[job] = t
+	if active {
+		s.Streak[job]++
+	} else {
+		s.Streak[job] = 0
+	}
+	return s.save()
+}
+
+// markDelivered records that dest received the run of job at t.
+func (s *reportState) markDelivered(job, dest string, t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := job + "/" + dest
+	if t.Before(s.Delivered[key]) {
+		return nil
+	}
+	s.Delivered[key] = t
+	return s.save()
+}
+
+// save writes the state file atomically. The caller holds s.mu.
+func (s *reportState) save() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
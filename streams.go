@@ -0,0 +1,113 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// StreamStats counts how plays were delivered for one user or device.
+type StreamStats struct {
+	Name         string `json:"name"`
+	DirectPlay   int    `json:"direct_play"`
+	DirectStream int    `json:"direct_stream"`
+	Transcode    int    `json:"transcode"`
+}
+
+func (s *StreamStats) Total() int {
+	return s.DirectPlay + s.DirectStream + s.Transcode
+}
+
+// AlwaysTranscodes reports whether every play transcoded, which usually
+// points at a client app that cannot direct play the library's formats.
+func (s *StreamStats) AlwaysTranscodes() bool {
+	return s.Transcode > 0 && s.Transcode == s.Total()
+}
+
+func (s *StreamStats) add(decision string) {
+	switch strings.ToLower(decision) {
+	case "direct play":
+		s.DirectPlay++
+	case "copy", "direct stream":
+		s.DirectStream++
+	case "transcode":
+		s.Transcode++
+	}
+}
+
+// StreamHealth breaks down transcode decisions per user and per device.
+type StreamHealth struct {
+	Users   []*StreamStats `json:"users"`
+	Devices []*StreamStats `json:"devices"`
+}
+
+func buildStreamHealth(items []HistoryItem) StreamHealth {
+	var health StreamHealth
+	users := make(map[string]*StreamStats)
+	devices := make(map[string]*StreamStats)
+
+	for _, item := range items {
+		if item.TranscodeDecision == "" {
+			continue
+		}
+		user := users[item.Username]
+		if user == nil {
+			user = &StreamStats{Name: item.Username}
+			users[item.Username] = user
+			health.Users = append(health.Users, user)
+		}
+		user.add(item.TranscodeDecision)
+
+		name := deviceName(item)
+		device := devices[name]
+		if device == nil {
+			device = &StreamStats{Name: name}
+			devices[name] = device
+			health.Devices = append(health.Devices, device)
+		}
+		device.add(item.TranscodeDecision)
+	}
+
+	slices.SortStableFunc(health.Users, byTranscodes)
+	slices.SortStableFunc(health.Devices, byTranscodes)
+	return health
+}
+
+// byTranscodes orders stream stats by most transcodes first, then by name.
+func byTranscodes(a, b *StreamStats) int {
+	return cmp.Or(
+		cmp.Compare(b.Transcode, a.Transcode),
+		cmp.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)),
+		cmp.Compare(a.Name, b.Name),
+	)
+}
+
+// deviceName identifies a client by player name and app.
+func deviceName(item HistoryItem) string {
+	switch {
+	case item.Player == "":
+		return item.Product
+	case item.Product == "" || item.Product == item.Player:
+		return item.Player
+	}
+	return item.Player + " (" + item.Product + ")"
+}
+
+func writeStreamHealth(b *strings.Builder, health StreamHealth, m markup) {
+	if len(health.Users) == 0 {
+		return
+	}
+	b.WriteString(fmt.Sprintf("🎞 %s %s\n", m.bold("Stream health"), m.italic("(direct play / direct stream / transcode)")))
+	for _, u := range health.Users {
+		b.WriteString(fmt.Sprintf("  👤 %s: %d / %d / %d\n", m.escape(u.Name), u.DirectPlay, u.DirectStream, u.Transcode))
+	}
+	for _, d := range health.Devices {
+		warning := ""
+		if d.AlwaysTranscodes() {
+			warning = " ⚠️ always transcodes"
+		}
+		b.WriteString(fmt.Sprintf("  📱 %s: %d / %d / %d%s\n", m.escape(d.Name), d.DirectPlay, d.DirectStream, d.Transcode, warning))
+	}
+	b.WriteString("\n")
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Summary is the aggregated view of a HistoryData, built once and rendered
+// by any of the formats in format.go.
+type Summary struct {
+	Period string         `json:"period,omitempty"`
+	Users  []*UserSummary `json:"users"`
+	Live   LiveSummary    `json:"live"`
+	// TotalDuration is the sum of all item durations in seconds.
+	TotalDuration int `json:"total_duration"`
+	// ReportedDuration is Tautulli's own total for the query.
+	ReportedDuration string          `json:"reported_duration"`
+	Streams          StreamHealth    `json:"streams"`
+	Devices          DeviceBreakdown `json:"devices"`
+	Comparison       *Comparison     `json:"comparison,omitempty"`
+	// Progress is only filled when the progress section is enabled.
+	Progress []*SeriesProgress `json:"progress,omitempty"`
+	// Calendar is only filled when the calendar section is enabled.
+	Calendar *Calendar `json:"calendar,omitempty"`
+	// Completion is only filled when the completion section is enabled.
+	Completion *Completion `json:"completion,omitempty"`
+	// Sections lists the optional sections the renderers include.
+	Sections []string `json:"-"`
+
+	history []HistoryItem
+}
+
+// Optional summary sections, enabled by SUMMARY_SECTIONS or per report.
+const (
+	SectionStreams    = "streams"
+	SectionDevices    = "devices"
+	SectionProgress   = "progress"
+	SectionCalendar   = "calendar"
+	SectionCompletion = "completion"
+)
+
+var summarySections = []string{SectionStreams, SectionDevices, SectionProgress, SectionCalendar, SectionCompletion}
+
+func (s *Summary) hasSection(name string) bool {
+	return slices.Contains(s.Sections, name)
+}
+
+// parseSections validates a comma-separated list of section names.
+func parseSections(list string) ([]string, error) {
+	sections := splitList(strings.ToLower(list))
+	for _, section := range sections {
+		if !slices.Contains(summarySections, section) {
+			return nil, fmt.Errorf("unknown section %q (expected one of %s)", section, strings.Join(summarySections, ", "))
+		}
+	}
+	return sections, nil
+}
+
+type UserSummary struct {
+	Name   string         `json:"name"`
+	Items  []HistoryItem  `json:"items"`
+	Movies []*MovieGroup  `json:"movies"`
+	Shows  []*ShowGroup   `json:"shows"`
+	Music  []*ArtistGroup `json:"music"`
+	// Sessions are the items with binges collapsed, in order.
+	Sessions []*Session `json:"sessions"`
+	Duration int        `json:"duration"`
+	First    int64      `json:"first_watched"`
+}
+
+type MovieGroup struct {
+	Title    string `json:"title"`
+	Plays    int    `json:"plays"`
+	Duration int    `json:"duration"`
+	First    int64  `json:"first_watched"`
+}
+
+type ShowGroup struct {
+	Title    string `json:"title"`
+	Episodes int    `json:"episodes"`
+	Duration int    `json:"duration"`
+	First    int64  `json:"first_watched"`
+}
+
+type ArtistGroup struct {
+	Artist   string        `json:"artist"`
+	Albums   []*AlbumGroup `json:"albums"`
+	Tracks   int           `json:"tracks"`
+	Duration int           `json:"duration"`
+	First    int64         `json:"first_watched"`
+}
+
+type AlbumGroup struct {
+	Title    string `json:"title"`
+	Tracks   int    `json:"tracks"`
+	Duration int    `json:"duration"`
+	First    int64  `json:"first_watched"`
+}
+
+type LiveSummary struct {
+	Shows []*LiveGroup `json:"shows"`
+	// Users is the live watch time per user, longest first.
+	Users    []*UsageGroup `json:"users"`
+	Duration int           `json:"duration"`
+}
+
+type LiveGroup struct {
+	Title    string `json:"title"`
+	Duration int    `json:"duration"`
+	First    int64  `json:"first_watched"`
+}
+
+// buildSummary aggregates the history by user, show and movie. Live TV is
+// kept separately from the users' own sections.
+func buildSummary(data *HistoryData) *Summary {
+	s := &Summary{
+		history:          data.History,
+		ReportedDuration: data.TotalDuration,
+		Streams:          buildStreamHealth(data.History),
+		Devices:          buildDeviceBreakdown(data.History),
+		Sections:         AppConfig.Sections,
+	}
+	users := make(map[string]*UserSummary)
+	live := make(map[string]*LiveGroup)
+	var liveUsers usageSet
+
+	for _, item := range data.History {
+		s.TotalDuration += item.Duration
+
+		if item.Live == 1 {
+			title := showTitle(item)
+			group := live[title]
+			if group == nil {
+				group = &LiveGroup{Title: title}
+				live[title] = group
+				s.Live.Shows = append(s.Live.Shows, group)
+			}
+			group.Duration += item.Duration
+			group.First = earliest(group.First, item.Date)
+			s.Live.Duration += item.Duration
+			liveUsers.add(item.Username, item.Duration)
+			continue
+		}
+
+		user := users[item.Username]
+		if user == nil {
+			user = &UserSummary{Name: item.Username}
+			users[item.Username] = user
+			s.Users = append(s.Users, user)
+		}
+		user.addItem(item)
+	}
+
+	s.Live.Users = liveUsers.sorted()
+	s.sort(AppConfig.SortBy)
+	for _, u := range s.Users {
+		u.Sessions = groupSessions(u.Items, AppConfig.BingeGap)
+	}
+	return s
+}
+
+func (u *UserSummary) addItem(item HistoryItem) {
+	u.Items = append(u.Items, item)
+	u.Duration += item.Duration
+	u.First = earliest(u.First, item.Date)
+
+	switch item.MediaType {
+	case "movie":
+		var group *MovieGroup
+		for _, g := range u.Movies {
+			if g.Title == item.Title {
+				group = g
+				break
+			}
+		}
+		if group == nil {
+			group = &MovieGroup{Title: item.Title}
+			u.Movies = append(u.Movies, group)
+		}
+		group.Plays++
+		group.Duration += item.Duration
+		group.First = earliest(group.First, item.Date)
+
+	case "episode":
+		title := showTitle(item)
+		var group *ShowGroup
+		for _, g := range u.Shows {
+			if g.Title == title {
+				group = g
+				break
+			}
+		}
+		if group == nil {
+			group = &ShowGroup{Title: title}
+			u.Shows = append(u.Shows, group)
+		}
+		group.Episodes++
+		group.Duration += item.Duration
+		group.First = earliest(group.First, item.Date)
+
+	case "track":
+		artist := showTitle(item)
+		var group *ArtistGroup
+		for _, g := range u.Music {
+			if g.Artist == artist {
+				group = g
+				break
+			}
+		}
+		if group == nil {
+			group = &ArtistGroup{Artist: artist}
+			u.Music = append(u.Music, group)
+		}
+		group.Tracks++
+		group.Duration += item.Duration
+		group.First = earliest(group.First, item.Date)
+
+		album := item.ParentTitle
+		if album == "" {
+			album = "Unknown album"
+		}
+		var albumGroup *AlbumGroup
+		for _, g := range group.Albums {
+			if g.Title == album {
+				albumGroup = g
+				break
+			}
+		}
+		if albumGroup == nil {
+			albumGroup = &AlbumGroup{Title: album}
+			group.Albums = append(group.Albums, albumGroup)
+		}
+		albumGroup.Tracks++
+		albumGroup.Duration += item.Duration
+		albumGroup.First = earliest(albumGroup.First, item.Date)
+	}
+}
+
+// loadSections fills the enabled sections that need further Tautulli
+// lookups. end is the last day of the summary's period. Call it once
+// Sections is final.
+func (s *Summary) loadSections(end time.Time) {
+	if s.hasSection(SectionProgress) {
+		s.Progress = fetchShowProgress(s.history)
+	}
+	if s.hasSection(SectionCompletion) {
+		s.Completion = buildCompletion(s.history)
+	}
+	if s.hasSection(SectionCalendar) {
+		calendar, err := fetchCalendar("", end)
+		if err != nil {
+			log.Printf("Calendar failed: %v", err)
+			return
+		}
+		var names []string
+		for _, u := range s.Users {
+			names = append(names, u.Name)
+		}
+		calendar.onlyUsers(names)
+		s.Calendar = calendar
+	}
+}
+
+// Items returns every history entry the summary was built from, including
+// live TV.
+func (s *Summary) Items() []HistoryItem {
+	return s.history
+}
+
+// Empty reports whether nothing at all was watched in the period.
+func (s *Summary) Empty() bool {
+	return len(s.Users) == 0 && s.Live.Duration == 0 && len(s.Live.Shows) == 0
+}
+
+func (u *UserSummary) MovieDuration() int {
+	var total int
+	for _, g := range u.Movies {
+		total += g.Duration
+	}
+	return total
+}
+
+func (u *UserSummary) MusicDuration() (tracks, duration int) {
+	for _, g := range u.Music {
+		tracks += g.Tracks
+		duration += g.Duration
+	}
+	return tracks, duration
+}
+
+func (u *UserSummary) ShowDuration() (episodes, duration int) {
+	for _, g := range u.Shows {
+		episodes += g.Episodes
+		duration += g.Duration
+	}
+	return episodes, duration
+}
+
+// showTitle groups episodes and live programmes by their show and tracks by
+// their artist, falling back to the item title.
+func showTitle(item HistoryItem) string {
+	if item.GrandparentTitle != "" {
+		return item.GrandparentTitle
+	}
+	return item.Title
+}
+
+// Sort orders for users and titles.
+const (
+	SortWatchTime = "watchtime" // longest watch time first
+	SortName      = "name"      // alphabetical
+	SortFirst     = "first"     // earliest first watch first
+)
+
+var sortKeys = []string{SortWatchTime, SortName, SortFirst}
+
+// sort orders users, titles and live shows by key so that output is stable
+// between runs. Items within a user are always chronological. Ties are
+// broken by name.
+func (s *Summary) sort(key string) {
+	sortGroups(s.Users, key, func(u *UserSummary) (string, int, int64) { return u.Name, u.Duration, u.First })
+	sortGroups(s.Live.Shows, key, func(g *LiveGroup) (string, int, int64) { return g.Title, g.Duration, g.First })
+	for _, u := range s.Users {
+		slices.SortStableFunc(u.Items, func(a, b HistoryItem) int { return cmp.Compare(a.Date, b.Date) })
+		sortGroups(u.Movies, key, func(g *MovieGroup) (string, int, int64) { return g.Title, g.Duration, g.First })
+		sortGroups(u.Shows, key, func(g *ShowGroup) (string, int, int64) { return g.Title, g.Duration, g.First })
+		sortGroups(u.Music, key, func(g *ArtistGroup) (string, int, int64) { return g.Artist, g.Duration, g.First })
+		for _, g := range u.Music {
+			sortGroups(g.Albums, key, func(a *AlbumGroup) (string, int, int64) { return a.Title, a.Duration, a.First })
+		}
+	}
+}
+
+func sortGroups[T any](groups []T, key string, fields func(T) (name string, duration int, first int64)) {
+	slices.SortStableFunc(groups, func(a, b T) int {
+		nameA, durA, firstA := fields(a)
+		nameB, durB, firstB := fields(b)
+
+		var c int
+		switch key {
+		case SortWatchTime:
+			c = cmp.Compare(durB, durA)
+		case SortFirst:
+			c = cmp.Compare(firstA, firstB)
+		}
+		if c != 0 {
+			return c
+		}
+		return cmp.Or(
+			cmp.Compare(strings.ToLower(nameA), strings.ToLower(nameB)),
+			cmp.Compare(nameA, nameB),
+		)
+	})
+}
+
+func earliest(current, date int64) int64 {
+	if current == 0 || date < current {
+		return date
+	}
+	return current
+}
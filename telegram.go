@@ -1,17 +1,66 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"log"
+	"slices"
+	"strconv"
 	"strings"
-	"time"
 )
 
-func StartTelegramBot() {
+type telegramNotifier struct {
+	bot   *tgbotapi.BotAPI
+	chats []int64
+}
+
+func (telegramNotifier) Name() string { return "telegram" }
+
+func (telegramNotifier) Format() string { return FormatHTML }
+
+// Notify sends the message to every chat, so one unreachable chat does not
+// keep it from the others.
+func (n telegramNotifier) Notify(title, message string) error {
+	text := htmlMarkup.bold(htmlMarkup.escape(title)) + "\n\n" + message
+	var errs []error
+	for _, chatID := range n.chats {
+		if err := sendHTML(n.bot, chatID, text); err != nil {
+			errs = append(errs, fmt.Errorf("chat %d: %w", chatID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// sendHTML sends text with Telegram's HTML parse mode, split into as many
+// messages as needed.
+func sendHTML(bot *tgbotapi.BotAPI, chatID int64, text string) error {
+	for _, chunk := range splitMessage(text, telegramMaxLen, true) {
+		msg := tgbotapi.NewMessage(chatID, chunk)
+		msg.ParseMode = tgbotapi.ModeHTML
+		if _, err := bot.Send(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendPlain sends text without markup, split into as many messages as
+// needed. Failures are logged.
+func sendPlain(bot *tgbotapi.BotAPI, chatID int64, text string) {
+	for _, chunk := range splitMessage(text, telegramMaxLen, false) {
+		if _, err := bot.Send(tgbotapi.NewMessage(chatID, chunk)); err != nil {
+			log.Printf("Telegram send error: %v", err)
+			return
+		}
+	}
+}
+
+func newTelegramBot() (*tgbotapi.BotAPI, error) {
 	bot, err := tgbotapi.NewBotAPI(AppConfig.TelegramBotToken)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	bot.Debug = false
@@ -23,40 +72,67 @@ func StartTelegramBot() {
 		{Command: "lastweek", Description: "Summary for the last 7 days"},
 		{Command: "range", Description: "Summary for date range: /range YYYY-MM-DD YYYY-MM-DD"},
 		{Command: "all", Description: "Summary for all time"},
+		{Command: "user", Description: "Summary for one user: /user <name> [period]"},
+		{Command: "devices", Description: "Watch time by device: /devices [period]"},
+		{Command: "top", Description: "Leaderboard: /top [period] [users|shows|movies|devices|rewatches]"},
+		{Command: "peak", Description: "Simultaneous streams and busiest hours: /peak [period]"},
+		{Command: "completion", Description: "Finished, unfinished and abandoned titles: /completion [period]"},
+		{Command: "live", Description: "Live TV by user and channel: /live [period]"},
+		{Command: "wrapped", Description: "Year in review: /wrapped [year] [user]"},
+		{Command: "progress", Description: "Where everyone is in each show: /progress [user]"},
+		{Command: "calendar", Description: "Watch streaks and activity heatmap: /calendar [user]"},
 		{Command: "active", Description: "Show current Plex sessions"},
 	}
 
 	cfg := tgbotapi.NewSetMyCommands(commands...)
-	_, err = bot.Request(cfg)
-	if err != nil {
-		log.Fatal("Failed to set bot commands: ", err)
+	if _, err := bot.Request(cfg); err != nil {
+		return nil, fmt.Errorf("failed to set bot commands: %w", err)
 	}
+	return bot, nil
+}
 
+// runTelegramBot handles incoming commands until ctx is cancelled.
+func runTelegramBot(ctx context.Context, bot *tgbotapi.BotAPI) {
 	u := tgbotapi.NewUpdate(0)
 	u.Timeout = 60
 	updates := bot.GetUpdatesChan(u)
+	defer bot.StopReceivingUpdates()
 
-	for update := range updates {
-		if update.Message == nil || !update.Message.IsCommand() {
-			continue
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case update, ok := <-updates:
+			if !ok {
+				return
+			}
+			if update.Message == nil || !update.Message.IsCommand() {
+				continue
+			}
+			handleCommand(bot, update.Message)
 		}
+	}
+}
 
-		// Restrict to allowed users if list is non-empty
-		if len(AppConfig.AllowedTelegramIDs) > 0 &&
-			!AppConfig.AllowedTelegramIDs[update.Message.From.ID] {
+func handleCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
 
-			bot.Send(tgbotapi.NewMessage(update.Message.Chat.ID, "Access denied."))
-			continue
-		}
+	// Restrict to allowed users if list is non-empty
+	if len(AppConfig.AllowedTelegramIDs) > 0 &&
+		!AppConfig.AllowedTelegramIDs[message.From.ID] {
 
-		cmd := update.Message.Command()
-		args := update.Message.CommandArguments()
+		bot.Send(tgbotapi.NewMessage(chatID, "Access denied."))
+		return
+	}
+
+	cmd := message.Command()
+	args := message.CommandArguments()
 
-		switch cmd {
+	switch cmd {
 
-		case "start":
-			log.Printf("Received message from user ID: %d (%s)", update.Message.From.ID, update.Message.From.UserName)
-			msg := fmt.Sprintf(`Hello! Your Telegram ID is %d.
+	case "start":
+		log.Printf("Received message from user ID: %d (%s)", message.From.ID, message.From.UserName)
+		msg := fmt.Sprintf(`Hello! Your Telegram ID is %d.
 
 				Available commands:
 				/today - Summary for today
@@ -64,83 +140,263 @@ func StartTelegramBot() {
 				/lastweek - Summary for last 7 days
 				/range YYYY-MM-DD YYYY-MM-DD - Summary for custom date range
 				/all - Summary for all time
+				/user <name> [period] - Summary for one user
+				/devices [period] - Watch time by platform, app and player
+				/top [period] [users|shows|movies|devices|rewatches] - Leaderboards
+				/peak [period] - Simultaneous streams and busiest hours
+				/completion [period] - Finished, unfinished and abandoned titles
+				/live [period] - Live TV by user and channel
+				/wrapped [year] [user] - Year in review
+				/progress [user] - Where everyone is in each show
+				/calendar [user] - Watch streaks and activity heatmap
 				/active - Show current Plex sessions
-				`, update.Message.From.ID)
+				`, message.From.ID)
 
-			bot.Send(tgbotapi.NewMessage(update.Message.Chat.ID, msg))
+		bot.Send(tgbotapi.NewMessage(chatID, msg))
 
-		case "today":
-			date := time.Now().Format(dateLayout)
-			opts := HistoryRequest{StartDate: date}
-			sendTelegramSummary(bot, update.Message.Chat.ID, opts)
+	case "today", "yesterday", "lastweek", "all":
+		opts, _ := keywordRequest(cmd, now())
+		rest, err := parseFilterArgs(&opts, strings.Fields(args))
+		if err != nil || len(rest) > 0 {
+			bot.Send(tgbotapi.NewMessage(chatID, usageError("/"+cmd+" [type=movie|episode|track|live] [library=<name>]", err)))
+			return
+		}
+		sendTelegramSummary(bot, chatID, opts)
 
-		case "yesterday":
-			date := time.Now().AddDate(0, 0, -1).Format(dateLayout)
-			opts := HistoryRequest{StartDate: date}
-			sendTelegramSummary(bot, update.Message.Chat.ID, opts)
+	case "range":
+		opts := HistoryRequest{Compressed: true} // 👈 enable compression
+		dates, err := parseFilterArgs(&opts, strings.Fields(args))
+		if err != nil || len(dates) != 2 || !isDate(dates[0]) || !isDate(dates[1]) {
+			bot.Send(tgbotapi.NewMessage(chatID, usageError("/range YYYY-MM-DD YYYY-MM-DD [type=...] [library=...]", err)))
+			return
+		}
+		opts.AfterDate, opts.BeforeDate = dates[0], dates[1]
+		sendTelegramSummary(bot, chatID, opts)
 
-		case "lastweek":
-			after := time.Now().AddDate(0, 0, -7).Format(dateLayout)
-			opts := HistoryRequest{AfterDate: after}
-			sendTelegramSummary(bot, update.Message.Chat.ID, opts)
+	case "user":
+		opts, rest, err := parsePeriodArgs(strings.Fields(args), now(), PeriodLastWeek)
+		if err == nil {
+			rest, err = parseFilterArgs(&opts, rest)
+		}
+		if err != nil || len(rest) == 0 {
+			bot.Send(tgbotapi.NewMessage(chatID, usageError("/user <name> [period] [type=...] [library=...]", err)))
+			return
+		}
+		user, err := findUser(strings.Join(rest, " "))
+		if err != nil {
+			bot.Send(tgbotapi.NewMessage(chatID, err.Error()))
+			return
+		}
+		opts.UserID = user.UserID
+		sendTelegramSummary(bot, chatID, opts)
 
-		case "range":
-			dates := strings.Fields(args)
-			if len(dates) != 2 {
-				bot.Send(tgbotapi.NewMessage(update.Message.Chat.ID, "Usage: /range YYYY-MM-DD YYYY-MM-DD"))
-				continue
+	case "devices":
+		opts, err := parseCommandPeriod(args, PeriodLastWeek)
+		if err != nil {
+			bot.Send(tgbotapi.NewMessage(chatID, usageError("/devices [period] [type=...] [library=...]", err)))
+			return
+		}
+		sendTelegramReport(bot, chatID, opts, func(s *Summary) (string, error) {
+			return renderDevices(s, FormatHTML)
+		})
+
+	case "top":
+		category := TopUsers
+		var periodArgs []string
+		for _, arg := range strings.Fields(args) {
+			if slices.Contains(topCategories, strings.ToLower(arg)) {
+				category = strings.ToLower(arg)
+			} else {
+				periodArgs = append(periodArgs, arg)
 			}
-			opts := HistoryRequest{
-				AfterDate:  dates[0],
-				BeforeDate: dates[1],
-				Compressed: true, // 👈 enable compression
+		}
+		opts, err := parseCommandPeriod(strings.Join(periodArgs, " "), PeriodLastWeek)
+		if err != nil {
+			bot.Send(tgbotapi.NewMessage(chatID, usageError("/top [period] [users|shows|movies|devices|rewatches]", err)))
+			return
+		}
+		sendTelegramReport(bot, chatID, opts, func(s *Summary) (string, error) {
+			if category == TopRewatches {
+				markRewatches(s.Items())
 			}
-			sendTelegramSummary(bot, update.Message.Chat.ID, opts)
+			board := buildLeaderboard(s.Items(), category)
+			board.Period = s.Period
+			return renderLeaderboard(board, FormatHTML)
+		})
 
-		case "all":
-			opts := HistoryRequest{
-				AllTime:    true,
-				Compressed: true, // 👈 enable compression
+	case "peak":
+		opts, err := parseCommandPeriod(args, PeriodLastWeek)
+		if err != nil {
+			bot.Send(tgbotapi.NewMessage(chatID, usageError("/peak [period] [type=...] [library=...]", err)))
+			return
+		}
+		sendTelegramReport(bot, chatID, opts, func(s *Summary) (string, error) {
+			peak := buildPeak(s.Items())
+			peak.Period = s.Period
+			return renderPeak(peak, FormatHTML)
+		})
+
+	case "completion":
+		opts, err := parseCommandPeriod(args, PeriodAll)
+		if err != nil {
+			bot.Send(tgbotapi.NewMessage(chatID, usageError("/completion [period] [type=...] [library=...]", err)))
+			return
+		}
+		sendTelegramReport(bot, chatID, opts, func(s *Summary) (string, error) {
+			completion := buildCompletion(s.Items())
+			completion.Abandoned = findAbandoned(s.Items(), now())
+			completion.Period = s.Period
+			return renderCompletion(completion, FormatHTML)
+		})
+
+	case "live":
+		// Live TV is not filtered by type or library, so only a period is
+		// accepted.
+		opts, rest, err := parsePeriodArgs(strings.Fields(args), now(), PeriodLastWeek)
+		if err == nil && len(rest) > 0 {
+			if strings.Contains(rest[0], "=") {
+				err = fmt.Errorf("/live does not take filters like %q", rest[0])
+			} else {
+				err = fmt.Errorf("unexpected argument %q", rest[0])
 			}
-			sendTelegramSummary(bot, update.Message.Chat.ID, opts)
+		}
+		if err != nil {
+			bot.Send(tgbotapi.NewMessage(chatID, usageError("/live [period]", err)))
+			return
+		}
+		sendTelegramReport(bot, chatID, opts, func(s *Summary) (string, error) {
+			live := buildLiveStats(s.Items())
+			live.Period = s.Period
+			return renderLive(live, FormatHTML)
+		})
 
-		case "active":
-			text, err := fetchActiveSessions()
-			if err != nil {
-				text = "Error fetching active sessions: " + err.Error()
+	case "wrapped":
+		year := defaultWrappedYear(now())
+		var name []string
+		for _, arg := range strings.Fields(args) {
+			if y, err := strconv.Atoi(arg); err == nil && len(arg) == 4 {
+				year = y
+			} else {
+				name = append(name, arg)
+			}
+		}
+		var text string
+		wrapped, err := fetchWrapped(year, strings.Join(name, " "))
+		if err == nil {
+			text, err = renderWrapped(wrapped, FormatHTML)
+		}
+		if err != nil {
+			bot.Send(tgbotapi.NewMessage(chatID, "Error: "+err.Error()))
+			return
+		}
+		if err := sendHTML(bot, chatID, text); err != nil {
+			log.Printf("Telegram send error: %v", err)
+		}
+
+	case "progress":
+		var text string
+		progress, err := fetchProgress(args)
+		if err == nil {
+			text, err = renderProgress(progress, FormatHTML)
+		}
+		if err != nil {
+			bot.Send(tgbotapi.NewMessage(chatID, "Error: "+err.Error()))
+			return
+		}
+		if err := sendHTML(bot, chatID, text); err != nil {
+			log.Printf("Telegram send error: %v", err)
+		}
+
+	case "calendar":
+		var text string
+		calendar, err := fetchCalendar(args, now())
+		if err == nil {
+			text, err = renderCalendar(calendar, FormatHTML)
+		}
+		if err != nil {
+			bot.Send(tgbotapi.NewMessage(chatID, "Error: "+err.Error()))
+			return
+		}
+		if err := sendHTML(bot, chatID, text); err != nil {
+			log.Printf("Telegram send error: %v", err)
+		}
+
+	case "active":
+		var text string
+		sessions, err := fetchActiveSessions()
+		if err == nil {
+			text, err = renderActive(sessions, FormatHTML)
+		}
+		if err != nil {
+			bot.Send(tgbotapi.NewMessage(chatID, "Error fetching active sessions: "+err.Error()))
+			return
+		}
+		if err := sendHTML(bot, chatID, text); err != nil {
+			log.Printf("Telegram send error: %v", err)
+			// Retry without markup in case the HTML was rejected.
+			if text, err := renderActive(sessions, FormatText); err == nil {
+				sendPlain(bot, chatID, text)
 			}
-			bot.Send(tgbotapi.NewMessage(update.Message.Chat.ID, text))
 		}
 	}
 }
 
 func sendTelegramSummary(bot *tgbotapi.BotAPI, chatID int64, opts HistoryRequest) {
+	sendTelegramReport(bot, chatID, opts, func(s *Summary) (string, error) {
+		s.loadSections(opts.End(now()))
+		if !opts.Compressed {
+			s.loadRewatches()
+		}
+		return renderSummary(s, FormatHTML, opts.Compressed)
+	})
+}
+
+// sendTelegramReport fetches the history for opts and sends whatever render
+// makes of its summary.
+func sendTelegramReport(bot *tgbotapi.BotAPI, chatID int64, opts HistoryRequest, render func(*Summary) (string, error)) {
 	data, err := fetchAllHistory(opts)
 	if err != nil {
 		bot.Send(tgbotapi.NewMessage(chatID, "Error: "+err.Error()))
 		return
 	}
 
-	summary := generateSummary(data, opts.Compressed)
-	chunks := splitMessage(summary, 4096)
+	summary := buildSummary(data)
+	summary.Period = opts.Label()
+	if summary.Empty() {
+		bot.Send(tgbotapi.NewMessage(chatID, noActivityText))
+		return
+	}
 
-	for _, chunk := range chunks {
-		bot.Send(tgbotapi.NewMessage(chatID, chunk))
+	text, err := render(summary)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "Error: "+err.Error()))
+		return
+	}
+	if err := sendHTML(bot, chatID, text); err != nil {
+		log.Printf("Telegram send error: %v", err)
 	}
 }
 
-func splitMessage(s string, maxLen int) []string {
-	var parts []string
-	for len(s) > maxLen {
-		splitAt := strings.LastIndex(s[:maxLen], "\n")
-		if splitAt == -1 {
-			splitAt = maxLen
-		}
-		parts = append(parts, s[:splitAt])
-		s = s[splitAt:]
+// parseCommandPeriod parses "[period] [filters]" arguments, rejecting
+// anything else.
+func parseCommandPeriod(args string, def string) (HistoryRequest, error) {
+	opts, rest, err := parsePeriodArgs(strings.Fields(args), now(), def)
+	if err != nil {
+		return HistoryRequest{}, err
+	}
+	if rest, err = parseFilterArgs(&opts, rest); err != nil {
+		return HistoryRequest{}, err
 	}
-	if len(s) > 0 {
-		parts = append(parts, s)
+	if len(rest) > 0 {
+		return HistoryRequest{}, fmt.Errorf("unexpected argument %q", rest[0])
+	}
+	return opts, nil
+}
+
+// usageError explains why a command was rejected, followed by its usage.
+func usageError(usage string, err error) string {
+	if err != nil {
+		return err.Error() + "\nUsage: " + usage
 	}
-	return parts
+	return "Usage: " + usage
 }
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	htmltemplate "html/template"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// Layouts that can be replaced by a user template.
+const (
+	LayoutDaily      = "daily"
+	LayoutAggregated = "aggregated"
+	LayoutActive     = "active"
+)
+
+// templateExtensions maps output formats to the file name part used in
+// TEMPLATE_DIR, e.g. daily.txt.tmpl or aggregated.html.tmpl.
+var templateExtensions = map[string]string{
+	FormatText:     "txt",
+	FormatMarkdown: "md",
+	FormatHTML:     "html",
+}
+
+type executor interface {
+	Execute(w io.Writer, data any) error
+}
+
+// userTemplates holds the templates found in TEMPLATE_DIR, keyed by
+// layout and format.
+var userTemplates = make(map[string]executor)
+
+// templateFuncs are available to every user template.
+var templateFuncs = map[string]any{
+	"formatDuration": formatDuration,
+	"mediaIcon":      MediaIcon,
+	"watchedStatus":  watchedStatus,
+	"episodeCode":    episodeCode,
+	"itemLine":       FormatSummary,
+	"formatTime": func(unix int64) string {
+		return time.Unix(unix, 0).In(AppConfig.Location).Format("15:04:05")
+	},
+	"formatDate": func(unix int64) string {
+		return time.Unix(unix, 0).In(AppConfig.Location).Format(dateLayout)
+	},
+	// escapeMarkdown is for md templates, which are not escaped
+	// automatically.
+	"escapeMarkdown": markdownMarkup.escape,
+	"upper":          strings.ToUpper,
+	"lower":          strings.ToLower,
+}
+
+// loadTemplates parses <layout>.<txt|md|html>.tmpl files from dir. HTML
+// templates use html/template so titles are escaped automatically.
+func loadTemplates(dir string) error {
+	for _, layout := range []string{LayoutDaily, LayoutAggregated, LayoutActive} {
+		for format, ext := range templateExtensions {
+			path := filepath.Join(dir, layout+"."+ext+".tmpl")
+			src, err := os.ReadFile(path)
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			var tmpl executor
+			if format == FormatHTML {
+				tmpl, err = htmltemplate.New(filepath.Base(path)).Funcs(templateFuncs).Parse(string(src))
+			} else {
+				tmpl, err = texttemplate.New(filepath.Base(path)).Funcs(templateFuncs).Parse(string(src))
+			}
+			if err != nil {
+				return err
+			}
+			userTemplates[layout+"."+format] = tmpl
+			log.Printf("Loaded %s template for %s output", layout, format)
+		}
+	}
+	return nil
+}
+
+// executeTemplate renders data with the user template for layout and
+// format. It reports false when there is no such template or it failed, in
+// which case the built-in layout is used.
+func executeTemplate(layout, format string, data any) (string, bool) {
+	tmpl, ok := userTemplates[layout+"."+format]
+	if !ok {
+		return "", false
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		log.Printf("Template %s.%s failed, using built-in layout: %v", layout, format, err)
+		return "", false
+	}
+	return b.String(), true
+}
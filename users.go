@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// TautulliUser is a Plex user known to Tautulli.
+type TautulliUser struct {
+	UserID       int    `json:"user_id"`
+	Username     string `json:"username"`
+	FriendlyName string `json:"friendly_name"`
+}
+
+type UsersResponse struct {
+	Response struct {
+		Data []TautulliUser `json:"data"`
+	} `json:"response"`
+}
+
+func fetchUsers() ([]TautulliUser, error) {
+	url := fmt.Sprintf("%s/api/v2?apikey=%s&cmd=get_users", AppConfig.TautulliURL, AppConfig.APIKey)
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result UsersResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return result.Response.Data, nil
+}
+
+// findUser resolves name against the Tautulli users. It accepts an exact
+// (case-insensitive) username or friendly name, or a unique prefix of one.
+// Otherwise the error lists the closest names.
+func findUser(name string) (TautulliUser, error) {
+	users, err := fetchUsers()
+	if err != nil {
+		return TautulliUser{}, err
+	}
+	return matchUser(users, name)
+}
+
+func matchUser(users []TautulliUser, name string) (TautulliUser, error) {
+	query := strings.ToLower(strings.TrimSpace(name))
+	if query == "" {
+		return TautulliUser{}, fmt.Errorf("no user given")
+	}
+
+	for _, u := range users {
+		if strings.ToLower(u.Username) == query || strings.ToLower(u.FriendlyName) == query {
+			return u, nil
+		}
+	}
+
+	var prefixed []TautulliUser
+	for _, u := range users {
+		if strings.HasPrefix(strings.ToLower(u.Username), query) || strings.HasPrefix(strings.ToLower(u.FriendlyName), query) {
+			prefixed = append(prefixed, u)
+		}
+	}
+	if len(prefixed) == 1 {
+		return prefixed[0], nil
+	}
+	if len(prefixed) > 1 {
+		return TautulliUser{}, fmt.Errorf("%q is ambiguous, did you mean: %s", name, userNames(prefixed))
+	}
+
+	// Suggest the users within a few edits of the query.
+	type candidate struct {
+		user     TautulliUser
+		distance int
+	}
+	var candidates []candidate
+	for _, u := range users {
+		d := min(levenshtein(query, strings.ToLower(u.Username)), levenshtein(query, strings.ToLower(u.FriendlyName)))
+		if d <= max(2, len(query)/3) {
+			candidates = append(candidates, candidate{u, d})
+		}
+	}
+	if len(candidates) == 0 {
+		return TautulliUser{}, fmt.Errorf("unknown user %q", name)
+	}
+	slices.SortStableFunc(candidates, func(a, b candidate) int { return a.distance - b.distance })
+	var close []TautulliUser
+	for _, c := range candidates[:min(3, len(candidates))] {
+		close = append(close, c.user)
+	}
+	return TautulliUser{}, fmt.Errorf("unknown user %q, did you mean: %s", name, userNames(close))
+}
+
+// DisplayName is the name Tautulli shows in history entries.
+func (u TautulliUser) DisplayName() string {
+	if u.FriendlyName != "" {
+		return u.FriendlyName
+	}
+	return u.Username
+}
+
+func userNames(users []TautulliUser) string {
+	names := make([]string, len(users))
+	for i, u := range users {
+		names[i] = u.DisplayName()
+	}
+	return strings.Join(names, ", ")
+}
+
+// levenshtein returns the edit distance between a and b, counted in runes.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// wrappedTopSize is how many shows and movies a Wrapped recap lists.
+const wrappedTopSize = 5
+
+// Wrapped is a year-in-review recap per user.
+type Wrapped struct {
+	Year  int            `json:"year"`
+	Users []*UserWrapped `json:"users"`
+}
+
+type UserWrapped struct {
+	Name           string        `json:"name"`
+	UserID         int           `json:"user_id"`
+	Duration       int           `json:"duration"`
+	Plays          int           `json:"plays"`
+	TopShows       []*UsageGroup `json:"top_shows"`
+	TopMovies      []*UsageGroup `json:"top_movies"`
+	BingeDay       string        `json:"binge_day"`
+	BingeDuration  int           `json:"binge_duration"`
+	FavoriteDevice string        `json:"favorite_device"`
+	LongestStreak  int           `json:"longest_streak"`
+	First          HistoryItem   `json:"first"`
+	Last           HistoryItem   `json:"last"`
+	// Percentile is the share of other users with less watch time, or -1
+	// when there is nobody to compare with.
+	Percentile int `json:"percentile"`
+}
+
+func wrappedRequest(year int) HistoryRequest {
+	return HistoryRequest{
+		AfterDate:  fmt.Sprintf("%04d-01-01", year),
+		BeforeDate: fmt.Sprintf("%04d-12-31", year),
+	}
+}
+
+// buildWrapped computes the recap of every user in items. Live TV counts
+// towards the user who watched it.
+func buildWrapped(items []HistoryItem, year int) *Wrapped {
+	w := &Wrapped{Year: year}
+	byUser := make(map[string][]HistoryItem)
+	var names []string
+	for _, item := range items {
+		if _, ok := byUser[item.Username]; !ok {
+			names = append(names, item.Username)
+		}
+		byUser[item.Username] = append(byUser[item.Username], item)
+	}
+
+	for _, name := range names {
+		w.Users = append(w.Users, buildUserWrapped(name, byUser[name]))
+	}
+	sortGroups(w.Users, SortWatchTime, func(u *UserWrapped) (string, int, int64) { return u.Name, u.Duration, 0 })
+
+	for _, u := range w.Users {
+		u.Percentile = -1
+		if len(w.Users) > 1 {
+			var less int
+			for _, other := range w.Users {
+				if other.Duration < u.Duration {
+					less++
+				}
+			}
+			u.Percentile = less * 100 / (len(w.Users) - 1)
+		}
+	}
+	return w
+}
+
+func buildUserWrapped(name string, items []HistoryItem) *UserWrapped {
+	u := &UserWrapped{Name: name, UserID: items[0].UserID}
+	var shows, movies, devices, days usageSet
+
+	slices.SortStableFunc(items, func(a, b HistoryItem) int { return cmp.Compare(a.Date, b.Date) })
+	for _, item := range items {
+		u.Duration += item.Duration
+		u.Plays++
+		switch item.MediaType {
+		case "episode":
+			shows.add(showTitle(item), item.Duration)
+		case "movie":
+			movies.add(item.Title, item.Duration)
+		}
+		devices.add(deviceName(item), item.Duration)
+		days.add(itemDay(item), item.Duration)
+	}
+
+	u.TopShows = topGroups(shows.sorted(), wrappedTopSize)
+	u.TopMovies = topGroups(movies.sorted(), wrappedTopSize)
+	if top := devices.sorted(); len(top) > 0 {
+		u.FavoriteDevice = top[0].Name
+	}
+	if top := days.sorted(); len(top) > 0 {
+		u.BingeDay, u.BingeDuration = top[0].Name, top[0].Duration
+	}
+
+	var active []string
+	for _, d := range days.groups {
+		active = append(active, d.Name)
+	}
+	u.LongestStreak, _ = longestStreak(active)
+
+	if len(items) > 0 {
+		u.First, u.Last = items[0], items[len(items)-1]
+	}
+	return u
+}
+
+// itemDay is the calendar day an item was watched on, in TIMEZONE.
+func itemDay(item HistoryItem) string {
+	return time.Unix(item.Date, 0).In(AppConfig.Location).Format(dateLayout)
+}
+
+// longestStreak returns the longest run of consecutive days in days
+// (YYYY-MM-DD, any order) and the day that run ended on.
+func longestStreak(days []string) (int, string) {
+	sorted := slices.Clone(days)
+	slices.Sort(sorted)
+	sorted = slices.Compact(sorted)
+
+	var best, current int
+	var bestEnd string
+	var prev time.Time
+	for _, day := range sorted {
+		t, err := time.Parse(dateLayout, day)
+		if err != nil {
+			continue
+		}
+		if current > 0 && t.Sub(prev) == 24*time.Hour {
+			current++
+		} else {
+			current = 1
+		}
+		if current > best {
+			best, bestEnd = current, day
+		}
+		prev = t
+	}
+	return best, bestEnd
+}
+
+func topGroups(groups []*UsageGroup, n int) []*UsageGroup {
+	return groups[:min(n, len(groups))]
+}
+
+func renderWrapped(w *Wrapped, format string) (string, error) {
+	if format == FormatJSON {
+		return renderJSON(w)
+	}
+	m, err := markupFor(format)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("🎁 %s\n\n", m.bold(fmt.Sprintf("Plex Wrapped %d", w.Year))))
+	if len(w.Users) == 0 {
+		b.WriteString("Nothing was watched this year.\n")
+		return b.String(), nil
+	}
+
+	for _, u := range w.Users {
+		b.WriteString(fmt.Sprintf("👤 %s\n", m.bold(m.escape(u.Name))))
+		b.WriteString(fmt.Sprintf("  ⏱ %s hours across %s\n",
+			m.code(strconv.Itoa(u.Duration/3600)), plural(u.Plays, "play")))
+		if u.Percentile >= 0 {
+			b.WriteString(fmt.Sprintf("  📊 You watched more than %d%% of everyone else\n", u.Percentile))
+		}
+		writeWrappedTop(&b, "📺 Top shows", u.TopShows, m)
+		writeWrappedTop(&b, "🎬 Top movies", u.TopMovies, m)
+		if u.BingeDay != "" {
+			b.WriteString(fmt.Sprintf("  🍿 Biggest binge: %s with %s\n", u.BingeDay, m.code(formatDuration(u.BingeDuration))))
+		}
+		if u.FavoriteDevice != "" {
+			b.WriteString(fmt.Sprintf("  📱 Favorite device: %s\n", m.escape(u.FavoriteDevice)))
+		}
+		if u.LongestStreak > 1 {
+			b.WriteString(fmt.Sprintf("  🔥 Longest streak: %d days in a row\n", u.LongestStreak))
+		}
+		b.WriteString(fmt.Sprintf("  🌅 First watch: %s %s\n", m.escape(u.First.Title), m.italic(itemDay(u.First))))
+		b.WriteString(fmt.Sprintf("  🌙 Last watch: %s %s\n", m.escape(u.Last.Title), m.italic(itemDay(u.Last))))
+		b.WriteString("\n")
+	}
+	return b.String(), nil
+}
+
+func writeWrappedTop(b *strings.Builder, label string, groups []*UsageGroup, m markup) {
+	if len(groups) == 0 {
+		return
+	}
+	b.WriteString("  " + label + ":\n")
+	for i, g := range groups {
+		b.WriteString(fmt.Sprintf("    %d. %s %s\n", i+1, m.escape(g.Name), m.code(formatDuration(g.Duration))))
+	}
+}
+
+// filterUser keeps only the recap of the given user.
+func (w *Wrapped) filterUser(userID int) {
+	w.Users = slices.DeleteFunc(w.Users, func(u *UserWrapped) bool { return u.UserID != userID })
+}
+
+// defaultWrappedYear is the current year in December and the previous one
+// otherwise.
+func defaultWrappedYear(ref time.Time) int {
+	if ref.Month() == time.December {
+		return ref.Year()
+	}
+	return ref.Year() - 1
+}
+
+// fetchWrapped builds the recap for year, for a single user when userName
+// is set. Percentiles always compare against all users.
+func fetchWrapped(year int, userName string) (*Wrapped, error) {
+	var user TautulliUser
+	if userName != "" {
+		var err error
+		if user, err = findUser(userName); err != nil {
+			return nil, err
+		}
+	}
+
+	history, err := fetchAllHistory(wrappedRequest(year))
+	if err != nil {
+		return nil, err
+	}
+	w := buildWrapped(history.History, year)
+	if userName != "" {
+		w.filterUser(user.UserID)
+	}
+	return w, nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLongestStreak(t *testing.T) {
+	tests := []struct {
+		name    string
+		days    []string
+		want    int
+		wantEnd string
+	}{
+		{"no days", nil, 0, ""},
+		{"single day", []string{"2024-05-01"}, 1, "2024-05-01"},
+		{"unsorted with duplicates", []string{"2024-05-03", "2024-05-01", "2024-05-02", "2024-05-02"}, 3, "2024-05-03"},
+		{"gap ends a streak", []string{"2024-05-01", "2024-05-02", "2024-05-04", "2024-05-05", "2024-05-06"}, 3, "2024-05-06"},
+		{"first of equal streaks wins", []string{"2024-05-01", "2024-05-02", "2024-05-10", "2024-05-11"}, 2, "2024-05-02"},
+		{"across the year boundary", []string{"2023-12-30", "2023-12-31", "2024-01-01", "2024-01-02"}, 4, "2024-01-02"},
+		{"across the spring DST switch", []string{"2024-03-30", "2024-03-31", "2024-04-01"}, 3, "2024-04-01"},
+		{"across the autumn DST switch", []string{"2024-10-26", "2024-10-27", "2024-10-28"}, 3, "2024-10-28"},
+		{"leap day", []string{"2024-02-28", "2024-02-29", "2024-03-01"}, 3, "2024-03-01"},
+		{"invalid days are ignored", []string{"2024-05-01", "someday", "2024-05-02"}, 2, "2024-05-02"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, end := longestStreak(tt.days)
+			if got != tt.want || end != tt.wantEnd {
+				t.Errorf("longestStreak = %d ending %q, want %d ending %q", got, end, tt.want, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestWrappedStreakUsesLocalDays(t *testing.T) {
+	saved := AppConfig.Location
+	defer func() { AppConfig.Location = saved }()
+	AppConfig.Location = vienna(t)
+
+	// Late evening plays around the spring switch, one per local day. In
+	// UTC the first two fall on the same day.
+	var items []HistoryItem
+	for _, value := range []string{"2024-03-30 23:30", "2024-03-31 00:30", "2024-03-31 23:30", "2024-04-01 23:30"} {
+		items = append(items, HistoryItem{Username: "alice", MediaType: "movie", Date: at(t, value).Unix(), Duration: 600})
+	}
+	u := buildWrapped(items, 2024).Users[0]
+	if u.LongestStreak != 3 {
+		t.Errorf("LongestStreak = %d, want 3", u.LongestStreak)
+	}
+	if u.BingeDay != "2024-03-31" {
+		t.Errorf("BingeDay = %s, want 2024-03-31", u.BingeDay)
+	}
+}
+
+func TestWrappedPercentile(t *testing.T) {
+	saved := AppConfig.Location
+	defer func() { AppConfig.Location = saved }()
+	AppConfig.Location = time.UTC
+
+	tests := []struct {
+		name      string
+		durations map[string]int
+		want      map[string]int
+	}{
+		{"alone", map[string]int{"alice": 100}, map[string]int{"alice": -1}},
+		{"two users", map[string]int{"alice": 100, "bob": 50}, map[string]int{"alice": 100, "bob": 0}},
+		{"three users", map[string]int{"alice": 300, "bob": 200, "carol": 100}, map[string]int{"alice": 100, "bob": 50, "carol": 0}},
+		{"ties do not count", map[string]int{"alice": 300, "bob": 300, "carol": 100}, map[string]int{"alice": 50, "bob": 50, "carol": 0}},
+		{"rounded down", map[string]int{"a": 4, "b": 3, "c": 2, "d": 1}, map[string]int{"a": 100, "b": 66, "c": 33, "d": 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var items []HistoryItem
+			for name, d := range tt.durations {
+				items = append(items, HistoryItem{Username: name, MediaType: "movie", Duration: d})
+			}
+			for _, u := range buildWrapped(items, 2024).Users {
+				if u.Percentile != tt.want[u.Name] {
+					t.Errorf("%s: Percentile = %d, want %d", u.Name, u.Percentile, tt.want[u.Name])
+				}
+			}
+		})
+	}
+}